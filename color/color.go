@@ -72,38 +72,21 @@ var (
 	Cyan          = renderers{fg(ansi16.cyan), bg(ansi16.cyan)}
 	White         = renderers{fg(ansi16.white), bg(ansi16.white)}
 	BrightBlack   = renderers{fg(ansi16.brightblack), bg(ansi16.brightblack)}
-	BrightRed     = renderers{fg(ansi16.red), bg(ansi16.brightred)}
-	BrightGreen   = renderers{fg(ansi16.green), bg(ansi16.brightgreen)}
-	BrightYellow  = renderers{fg(ansi16.yellow), bg(ansi16.brightyellow)}
-	BrightBlue    = renderers{fg(ansi16.blue), bg(ansi16.brightblue)}
-	BrightMagenta = renderers{fg(ansi16.magenta), bg(ansi16.brightmagenta)}
-	BrightCyan    = renderers{fg(ansi16.cyan), bg(ansi16.brightcyan)}
-	BrightWhite   = renderers{fg(ansi16.white), bg(ansi16.brightwhite)}
+	BrightRed     = renderers{fg(ansi16.brightred), bg(ansi16.brightred)}
+	BrightGreen   = renderers{fg(ansi16.brightgreen), bg(ansi16.brightgreen)}
+	BrightYellow  = renderers{fg(ansi16.brightyellow), bg(ansi16.brightyellow)}
+	BrightBlue    = renderers{fg(ansi16.brightblue), bg(ansi16.brightblue)}
+	BrightMagenta = renderers{fg(ansi16.brightmagenta), bg(ansi16.brightmagenta)}
+	BrightCyan    = renderers{fg(ansi16.brightcyan), bg(ansi16.brightcyan)}
+	BrightWhite   = renderers{fg(ansi16.brightwhite), bg(ansi16.brightwhite)}
 	MiddleGray    = renderers{fg(truecolor.middlegray), bg(truecolor.middlegray)}
 )
 
-var status = map[bool]map[git.StatusCode]renderers{
-	true: {
-		git.Added:              Green,
-		git.Deleted:            Red,
-		git.Modified:           Green,
-		git.Renamed:            Yellow,
-		git.UpdatedButUnmerged: Green,
-	},
-	false: {
-		git.Added:              Red,
-		git.Deleted:            Red,
-		git.Modified:           Red,
-		git.Renamed:            Yellow,
-		git.UpdatedButUnmerged: Yellow,
-		git.Untracked:          Red,
-	},
-}
-
+// ByStatus renders s in the color the theme gives the status code.
 func ByStatus(s string, code git.StatusCode, staged bool) string {
-	renderer, ok := status[staged][code]
+	role, ok := statusRoles[staged][code]
 	if !ok {
 		return s
 	}
-	return renderer.Foreground(s)
+	return role.Foreground(s)
 }
@@ -0,0 +1,132 @@
+package color
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/git"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var profiles = []struct {
+	name    string
+	profile termenv.Profile
+}{
+	{"ascii", termenv.Ascii},
+	{"ansi", termenv.ANSI},
+	{"ansi256", termenv.ANSI256},
+	{"truecolor", termenv.TrueColor},
+}
+
+var allRenderers = []struct {
+	name string
+	r    renderers
+}{
+	{"Black", Black},
+	{"Red", Red},
+	{"Green", Green},
+	{"Yellow", Yellow},
+	{"Blue", Blue},
+	{"Magenta", Magenta},
+	{"Cyan", Cyan},
+	{"White", White},
+	{"BrightBlack", BrightBlack},
+	{"BrightRed", BrightRed},
+	{"BrightGreen", BrightGreen},
+	{"BrightYellow", BrightYellow},
+	{"BrightBlue", BrightBlue},
+	{"BrightMagenta", BrightMagenta},
+	{"BrightCyan", BrightCyan},
+	{"BrightWhite", BrightWhite},
+	{"MiddleGray", MiddleGray},
+}
+
+var statusCodes = []git.StatusCode{
+	git.Unmodified, git.Untracked, git.Modified, git.Added, git.Deleted,
+	git.Renamed, git.Copied, git.UpdatedButUnmerged,
+}
+
+// golden compares got with testdata/<name>.golden, or rewrites the file with
+// it when the tests are run with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from %s:\n%s", name, path, diffLines(string(want), got))
+	}
+}
+
+// diffLines lists the lines that differ between want and got.
+func diffLines(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < max(len(w), len(g)); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			fmt.Fprintf(&b, "line %d:\n\twant %s\n\tgot  %s\n", i+1, wl, gl)
+		}
+	}
+	return b.String()
+}
+
+// withProfile renders under each color profile in turn. The profile is global,
+// so the tests that use it cannot run in parallel.
+func withProfile(t *testing.T, render func(b *strings.Builder)) string {
+	t.Helper()
+	saved := gloss.ColorProfile()
+	t.Cleanup(func() { gloss.SetColorProfile(saved) })
+
+	var b strings.Builder
+	for _, p := range profiles {
+		gloss.SetColorProfile(p.profile)
+		fmt.Fprintf(&b, "# %s\n", p.name)
+		render(&b)
+	}
+	return b.String()
+}
+
+func TestRenderers(t *testing.T) {
+	got := withProfile(t, func(b *strings.Builder) {
+		for _, r := range allRenderers {
+			fmt.Fprintf(b, "%-13s fg %s bg %s\n", r.name,
+				strconv.Quote(r.r.Foreground("x")), strconv.Quote(r.r.Background("x")))
+		}
+	})
+	golden(t, "renderers", got)
+}
+
+func TestByStatus(t *testing.T) {
+	Use(Themes["default"])
+	got := withProfile(t, func(b *strings.Builder) {
+		for _, staged := range []bool{true, false} {
+			for _, code := range statusCodes {
+				fmt.Fprintf(b, "%q staged=%t %s\n", code, staged, strconv.Quote(ByStatus("x", code, staged)))
+			}
+		}
+	})
+	golden(t, "bystatus", got)
+}
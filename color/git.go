@@ -0,0 +1,210 @@
+package color
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/git"
+	"github.com/muesli/termenv"
+)
+
+// Mode says whether to color output, as color.ui does for git.
+type Mode string
+
+const (
+	// Auto colors output to a terminal, unless NO_COLOR is set.
+	Auto   Mode = "auto"
+	Always Mode = "always"
+	Never  Mode = "never"
+)
+
+// parseMode reads a mode the way git reads color.ui.
+func parseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "auto":
+		return Auto, nil
+	case "always":
+		return Always, nil
+	case "never", "false", "no", "off", "0":
+		return Never, nil
+	case "true", "yes", "on", "1", "":
+		// git takes a bare boolean to mean auto
+		return Auto, nil
+	}
+	return "", fmt.Errorf("%q must be auto, always or never", s)
+}
+
+// statusSlots gives the roles colored by the color.status.<slot> settings of
+// git. Those of the other slots are not shown by got.
+var statusSlots = map[string][]Role{
+	"header":    {Header},
+	"added":     {StagedAdded, StagedModified, StagedDeleted, StagedRenamed},
+	"updated":   {StagedAdded, StagedModified, StagedDeleted, StagedRenamed},
+	"changed":   {UnstagedAdded, UnstagedModified, UnstagedDeleted, UnstagedRenamed},
+	"untracked": {Untracked},
+	"unmerged":  {StagedUnmerged, UnstagedUnmerged},
+}
+
+// FromGit reads git's color settings, given as the entries of its color
+// section: the mode from color.status, or else color.ui, and the styles that
+// color.status.<slot> gives the roles.
+func FromGit(entries []git.ConfigEntry) (Mode, Theme, error) {
+	var (
+		ui, status Mode
+		theme      = make(Theme)
+	)
+	for _, e := range entries {
+		var err error
+		switch slot, ok := strings.CutPrefix(e.Key, "color.status."); {
+		case e.Key == "color.ui":
+			ui, err = parseMode(e.Value)
+		case e.Key == "color.status":
+			status, err = parseMode(e.Value)
+		case ok:
+			var style gloss.Style
+			if style, err = ParseStyle(e.Value); err == nil {
+				for _, r := range statusSlots[slot] {
+					theme[r] = style
+				}
+			}
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("%s in the git config: %w", e.Key, err)
+		}
+	}
+	mode := Auto
+	switch {
+	case status != "":
+		mode = status
+	case ui != "":
+		mode = ui
+	}
+	return mode, theme, nil
+}
+
+// SetMode chooses the color profile for the output. In auto mode, the output
+// is colored as much as the terminal supports, and not at all if it is not a
+// terminal or NO_COLOR is set. Always colors it even then, as git does.
+func SetMode(m Mode) {
+	switch m {
+	case Never:
+		gloss.SetColorProfile(termenv.Ascii)
+	case Always:
+		if p := gloss.ColorProfile(); p == termenv.Ascii {
+			gloss.SetColorProfile(envProfile())
+		}
+	}
+}
+
+// envProfile guesses the colors a terminal supports from its environment,
+// for when the output is not the terminal itself.
+func envProfile() termenv.Profile {
+	term := os.Getenv("TERM")
+	switch colorterm := os.Getenv("COLORTERM"); {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return termenv.TrueColor
+	case strings.Contains(term, "256color"):
+		return termenv.ANSI256
+	}
+	return termenv.ANSI
+}
+
+// Enabled reports whether output is colored.
+func Enabled() bool {
+	return gloss.ColorProfile() != termenv.Ascii
+}
+
+var (
+	hexColor   = regexp.MustCompile(`^#([0-9a-fA-F]{3}){1,2}$`)
+	colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+)
+
+// ParseStyle reads a style as git writes colors: up to two colors, the
+// foreground and then the background, and any attributes. A color is a name
+// such as red or brightred, a number from 0 to 255, or a hex color such as
+// #ff8700, and "normal" or "none" leaves it as it is. The attributes are bold,
+// dim, italic, ul, blink, reverse and strike, and can be turned off with a
+// "no" in front, as in nobold.
+func ParseStyle(s string) (gloss.Style, error) {
+	style := gloss.NewStyle()
+	colors := 0
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		if c, ok, err := parseColor(word); ok {
+			if err != nil {
+				return style, err
+			}
+			switch colors {
+			case 0:
+				style = style.Foreground(c)
+			case 1:
+				style = style.Background(c)
+			default:
+				return style, fmt.Errorf("%q has more than two colors", s)
+			}
+			colors++
+			continue
+		}
+
+		attr := strings.TrimPrefix(strings.TrimPrefix(word, "no"), "-")
+		on := attr == word
+		switch attr {
+		case "bold":
+			style = style.Bold(on)
+		case "dim":
+			style = style.Faint(on)
+		case "italic":
+			style = style.Italic(on)
+		case "ul", "underline":
+			style = style.Underline(on)
+		case "blink":
+			style = style.Blink(on)
+		case "reverse":
+			style = style.Reverse(on)
+		case "strike":
+			style = style.Strikethrough(on)
+		case "reset":
+		default:
+			return style, fmt.Errorf("%q is not a color or attribute in %q", word, s)
+		}
+	}
+	return style, nil
+}
+
+// parseColor reads a color of ParseStyle. It reports whether word looks like
+// a color at all, so that it is not taken for an attribute.
+func parseColor(word string) (gloss.TerminalColor, bool, error) {
+	switch word {
+	case "normal", "none", "default":
+		return gloss.NoColor{}, true, nil
+	}
+	name, bright := strings.CutPrefix(word, "bright")
+	for i, c := range colorNames {
+		if c == name {
+			if bright {
+				i += 8
+			}
+			return gloss.Color(strconv.Itoa(i)), true, nil
+		}
+	}
+	if strings.HasPrefix(word, "#") {
+		if !hexColor.MatchString(word) {
+			return nil, true, fmt.Errorf("%q is not a hex color", word)
+		}
+		return gloss.Color(word), true, nil
+	}
+	if n, err := strconv.Atoi(word); err == nil {
+		if n < -1 || n > 255 {
+			return nil, true, fmt.Errorf("%d is not a color from 0 to 255", n)
+		}
+		if n == -1 {
+			// git's number for normal
+			return gloss.NoColor{}, true, nil
+		}
+		return gloss.Color(word), true, nil
+	}
+	return nil, false, nil
+}
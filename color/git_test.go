@@ -0,0 +1,93 @@
+package color
+
+import (
+	"strings"
+	"testing"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/git"
+)
+
+func TestParseStyle(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		in         string
+		fg, bg     gloss.TerminalColor
+		bold, dim  bool
+		underlined bool
+	}{
+		{in: "red", fg: gloss.Color("1"), bg: gloss.NoColor{}},
+		{in: "brightred", fg: gloss.Color("9"), bg: gloss.NoColor{}},
+		{in: "bold green", fg: gloss.Color("2"), bg: gloss.NoColor{}, bold: true},
+		{in: "208 #000", fg: gloss.Color("208"), bg: gloss.Color("#000")},
+		{in: "normal blue dim", fg: gloss.NoColor{}, bg: gloss.Color("4"), dim: true},
+		{in: "#FF8700 ul nobold", fg: gloss.Color("#ff8700"), bg: gloss.NoColor{}, underlined: true},
+		{in: "-1 no-dim", fg: gloss.NoColor{}, bg: gloss.NoColor{}},
+	} {
+		s, err := ParseStyle(tt.in)
+		if err != nil {
+			t.Errorf("ParseStyle(%q): %v", tt.in, err)
+			continue
+		}
+		if s.GetForeground() != tt.fg || s.GetBackground() != tt.bg {
+			t.Errorf("ParseStyle(%q) colors = %v on %v, want %v on %v", tt.in,
+				s.GetForeground(), s.GetBackground(), tt.fg, tt.bg)
+		}
+		if s.GetBold() != tt.bold || s.GetFaint() != tt.dim || s.GetUnderline() != tt.underlined {
+			t.Errorf("ParseStyle(%q) bold, dim, ul = %t, %t, %t, want %t, %t, %t", tt.in,
+				s.GetBold(), s.GetFaint(), s.GetUnderline(), tt.bold, tt.dim, tt.underlined)
+		}
+	}
+
+	for _, in := range []string{"reddish", "red blue green", "256", "#ff87", "brightbold"} {
+		if _, err := ParseStyle(in); err == nil {
+			t.Errorf("ParseStyle(%q) accepted it", in)
+		}
+	}
+}
+
+func TestFromGit(t *testing.T) {
+	t.Parallel()
+
+	entry := func(key, value string) git.ConfigEntry {
+		return git.ConfigEntry{Key: key, Value: value}
+	}
+	for _, tt := range []struct {
+		entries []git.ConfigEntry
+		want    Mode
+	}{
+		{nil, Auto},
+		{[]git.ConfigEntry{entry("color.ui", "false")}, Never},
+		{[]git.ConfigEntry{entry("color.ui", "always"), entry("color.ui", "true")}, Auto},
+		{[]git.ConfigEntry{entry("color.status", "always"), entry("color.ui", "never")}, Always},
+	} {
+		mode, _, err := FromGit(tt.entries)
+		if err != nil || mode != tt.want {
+			t.Errorf("FromGit(%v) = %s, %v, want %s", tt.entries, mode, err, tt.want)
+		}
+	}
+
+	_, theme, err := FromGit([]git.ConfigEntry{
+		entry("color.status.changed", "yellow"),
+		entry("color.status.untracked", "bold"),
+		entry("color.status.branch", "blue"),
+	})
+	if err != nil {
+		t.Fatalf("FromGit: %v", err)
+	}
+	if got := theme[UnstagedDeleted].GetForeground(); got != gloss.Color("3") {
+		t.Errorf("unstaged-deleted = %v, want yellow from color.status.changed", got)
+	}
+	if !theme[Untracked].GetBold() {
+		t.Errorf("untracked is not bold")
+	}
+	if len(theme) != 5 {
+		t.Errorf("FromGit colored %d roles, want the 5 of changed and untracked", len(theme))
+	}
+
+	_, _, err = FromGit([]git.ConfigEntry{entry("color.ui", "sometimes")})
+	if want := `color.ui in the git config: "sometimes" must be auto, always or never`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
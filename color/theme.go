@@ -0,0 +1,341 @@
+package color
+
+import (
+	"slices"
+	"strings"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/git"
+)
+
+// Role is a part of the views that a theme gives a color.
+type Role string
+
+const (
+	Cursor    Role = "cursor"
+	Selection Role = "selection"
+	Separator Role = "separator"
+	Header    Role = "header"
+	Footer    Role = "footer"
+	// Message colors errors in the footer.
+	Message Role = "message"
+	// Restore dims files that are going to be restored.
+	Restore  Role = "restore"
+	Stash    Role = "stash"
+	Resolved Role = "resolved"
+	// Match highlights what matches a filter.
+	Match Role = "match"
+	// Marker runs along the lines of the current hunk or conflict.
+	Marker      Role = "marker"
+	HunkHeader  Role = "hunk-header"
+	DiffAdded   Role = "diff-added"
+	DiffDeleted Role = "diff-deleted"
+	// Checked and Unchecked color the options of a commit.
+	Checked   Role = "checked"
+	Unchecked Role = "unchecked"
+	// ConflictLabel colors the conflict markers and the labels of the sides.
+	ConflictLabel  Role = "conflict-label"
+	ConflictOurs   Role = "conflict-ours"
+	ConflictBase   Role = "conflict-base"
+	ConflictTheirs Role = "conflict-theirs"
+
+	// HeadBranch, HeadCommit, Operation and Progress color the header: the
+	// branch and commit HEAD points to, and the operation in progress with how
+	// far it got.
+	HeadBranch Role = "head-branch"
+	HeadCommit Role = "head-commit"
+	Operation  Role = "operation"
+	Progress   Role = "progress"
+	// Ref colors commits, stashes and snapshots named by their id or number.
+	Ref  Role = "ref"
+	Date Role = "date"
+	// Note dims what is said in passing, such as the titles of panes.
+	Note Role = "note"
+	// Warning and Danger color questions and hints that ask for care, Danger
+	// those about what cannot be taken back.
+	Warning Role = "warning"
+	Danger  Role = "danger"
+	// Upstream colors how a branch relates to its upstream.
+	Upstream Role = "upstream"
+	// RefHead, RefBranch, RefRemote and RefTag color the refs in the log, as
+	// git does; RefBranch and RefRemote also color the current and the remote
+	// branches in the branch list.
+	RefHead   Role = "ref-head"
+	RefBranch Role = "ref-branch"
+	RefRemote Role = "ref-remote"
+	RefTag    Role = "ref-tag"
+	// Search highlights what matches a search of the log.
+	Search Role = "search"
+	// DiffFile colors the paths above the diffs of a commit or stash.
+	DiffFile Role = "diff-file"
+	// The todo roles color the commands of a rebase other than pick.
+	TodoReword Role = "todo-reword"
+	TodoEdit   Role = "todo-edit"
+	TodoSquash Role = "todo-squash"
+	TodoDrop   Role = "todo-drop"
+	TodoExec   Role = "todo-exec"
+
+	StagedAdded      Role = "staged-added"
+	StagedModified   Role = "staged-modified"
+	StagedDeleted    Role = "staged-deleted"
+	StagedRenamed    Role = "staged-renamed"
+	StagedUnmerged   Role = "staged-unmerged"
+	UnstagedAdded    Role = "unstaged-added"
+	UnstagedModified Role = "unstaged-modified"
+	UnstagedDeleted  Role = "unstaged-deleted"
+	UnstagedRenamed  Role = "unstaged-renamed"
+	UnstagedUnmerged Role = "unstaged-unmerged"
+	Untracked        Role = "untracked"
+)
+
+// Roles lists all roles, in the order they are documented.
+var Roles = []Role{
+	Cursor, Selection, Separator, Header, Footer, Message, Restore, Stash, Resolved, Match,
+	Marker, HunkHeader, DiffAdded, DiffDeleted, Checked, Unchecked,
+	ConflictLabel, ConflictOurs, ConflictBase, ConflictTheirs,
+	HeadBranch, HeadCommit, Operation, Progress, Ref, Date, Note, Warning, Danger, Upstream,
+	RefHead, RefBranch, RefRemote, RefTag, Search, DiffFile,
+	TodoReword, TodoEdit, TodoSquash, TodoDrop, TodoExec,
+	StagedAdded, StagedModified, StagedDeleted, StagedRenamed, StagedUnmerged,
+	UnstagedAdded, UnstagedModified, UnstagedDeleted, UnstagedRenamed, UnstagedUnmerged, Untracked,
+}
+
+// Theme assigns styles to roles: a color, and attributes such as bold. A role
+// without a style is left as it is.
+type Theme map[Role]gloss.Style
+
+// Themes are the themes got comes with.
+var Themes = map[string]Theme{
+	"default": {
+		Cursor:           foreground(ansi16.magenta),
+		Selection:        foreground(ansi16.cyan),
+		Separator:        foreground(truecolor.middlegray),
+		Footer:           foreground(truecolor.middlegray),
+		Message:          foreground(ansi16.red),
+		Restore:          foreground(ansi16.brightblack),
+		Stash:            foreground(ansi16.blue),
+		Resolved:         foreground(ansi16.green),
+		Match:            foreground(ansi16.magenta),
+		Marker:           foreground(ansi16.magenta),
+		HunkHeader:       foreground(ansi16.cyan),
+		DiffAdded:        foreground(ansi16.green),
+		DiffDeleted:      foreground(ansi16.red),
+		Checked:          foreground(ansi16.magenta),
+		Unchecked:        foreground(truecolor.middlegray),
+		ConflictLabel:    foreground(ansi16.cyan),
+		ConflictOurs:     foreground(ansi16.green),
+		ConflictBase:     foreground(ansi16.brightblack),
+		ConflictTheirs:   foreground(ansi16.yellow),
+		HeadBranch:       foreground(ansi16.blue),
+		HeadCommit:       foreground(ansi16.cyan),
+		Operation:        foreground(ansi16.yellow),
+		Progress:         foreground(ansi16.cyan),
+		Ref:              foreground(ansi16.yellow),
+		Date:             foreground(truecolor.middlegray),
+		Note:             foreground(truecolor.middlegray),
+		Warning:          foreground(ansi16.yellow),
+		Danger:           foreground(ansi16.red),
+		Upstream:         foreground(ansi16.cyan),
+		RefHead:          foreground(ansi16.cyan),
+		RefBranch:        foreground(ansi16.green),
+		RefRemote:        foreground(ansi16.red),
+		RefTag:           foreground(ansi16.yellow),
+		Search:           gloss.NewStyle().Background(ansi16.yellow),
+		DiffFile:         foreground(ansi16.magenta),
+		TodoReword:       foreground(ansi16.cyan),
+		TodoEdit:         foreground(ansi16.yellow),
+		TodoSquash:       foreground(ansi16.magenta),
+		TodoDrop:         foreground(ansi16.brightblack),
+		TodoExec:         foreground(ansi16.blue),
+		StagedAdded:      foreground(ansi16.green),
+		StagedModified:   foreground(ansi16.green),
+		StagedDeleted:    foreground(ansi16.red),
+		StagedRenamed:    foreground(ansi16.yellow),
+		StagedUnmerged:   foreground(ansi16.green),
+		UnstagedAdded:    foreground(ansi16.red),
+		UnstagedModified: foreground(ansi16.red),
+		UnstagedDeleted:  foreground(ansi16.red),
+		UnstagedRenamed:  foreground(ansi16.yellow),
+		UnstagedUnmerged: foreground(ansi16.yellow),
+		Untracked:        foreground(ansi16.red),
+	},
+	"solarized": palette(map[Role]string{
+		Cursor:        "#d33682",
+		Selection:     "#2aa198",
+		Separator:     "#586e75",
+		Header:        "#93a1a1",
+		Footer:        "#586e75",
+		Message:       "#dc322f",
+		Restore:       "#586e75",
+		Stash:         "#268bd2",
+		Resolved:      "#859900",
+		Match:         "#b58900",
+		Marker:        "#d33682",
+		HunkHeader:    "#2aa198",
+		Checked:       "#d33682",
+		Unchecked:     "#586e75",
+		ConflictLabel: "#2aa198",
+		ConflictBase:  "#586e75",
+	}, "#859900", "#dc322f", "#b58900"),
+	"gruvbox": palette(map[Role]string{
+		Cursor:        "#fe8019",
+		Selection:     "#8ec07c",
+		Separator:     "#928374",
+		Header:        "#ebdbb2",
+		Footer:        "#928374",
+		Message:       "#fb4934",
+		Restore:       "#665c54",
+		Stash:         "#83a598",
+		Resolved:      "#b8bb26",
+		Match:         "#fabd2f",
+		Marker:        "#fe8019",
+		HunkHeader:    "#8ec07c",
+		Checked:       "#fe8019",
+		Unchecked:     "#928374",
+		ConflictLabel: "#8ec07c",
+		ConflictBase:  "#665c54",
+	}, "#b8bb26", "#fb4934", "#fabd2f"),
+	"high-contrast": palette(map[Role]string{
+		Cursor:        "13",
+		Selection:     "14",
+		Separator:     "15",
+		Header:        "15",
+		Footer:        "15",
+		Message:       "9",
+		Restore:       "7",
+		Stash:         "12",
+		Resolved:      "10",
+		Match:         "11",
+		Marker:        "13",
+		HunkHeader:    "14",
+		Checked:       "13",
+		Unchecked:     "7",
+		ConflictLabel: "14",
+		ConflictBase:  "7",
+	}, "10", "9", "11"),
+	// colorblind-safe uses the Okabe-Ito palette, with blue for staged changes
+	// and vermillion for unstaged ones in place of green and red.
+	"colorblind-safe": palette(map[Role]string{
+		Cursor:        "#cc79a7",
+		Selection:     "#009e73",
+		Separator:     "#808080",
+		Footer:        "#808080",
+		Message:       "#d55e00",
+		Restore:       "#808080",
+		Stash:         "#56b4e9",
+		Resolved:      "#0072b2",
+		Match:         "#e69f00",
+		Marker:        "#cc79a7",
+		HunkHeader:    "#009e73",
+		Checked:       "#cc79a7",
+		Unchecked:     "#808080",
+		ConflictLabel: "#009e73",
+		ConflictBase:  "#808080",
+	}, "#0072b2", "#d55e00", "#f0e442"),
+}
+
+// alike gives the roles that palette themes color like another one, unless
+// they give them a color of their own.
+var alike = map[Role]Role{
+	HeadBranch: Stash,
+	TodoExec:   Stash,
+	HeadCommit: Selection,
+	Progress:   Selection,
+	Upstream:   Selection,
+	RefHead:    Selection,
+	TodoReword: Selection,
+	Date:       Footer,
+	Note:       Footer,
+	DiffFile:   Cursor,
+	TodoSquash: Cursor,
+	TodoDrop:   Restore,
+}
+
+// palette makes a theme that colors status codes, diffs, the sides of
+// conflicts and refs the way the default theme does, with good, bad and moved
+// standing in for green, red and yellow.
+func palette(roles map[Role]string, good, bad, moved string) Theme {
+	t := make(Theme)
+	for r, c := range roles {
+		t[r] = foreground(gloss.Color(c))
+	}
+	for r, like := range alike {
+		if _, ok := roles[r]; !ok {
+			if c, ok := roles[like]; ok {
+				t[r] = foreground(gloss.Color(c))
+			}
+		}
+	}
+	for _, r := range []Role{StagedAdded, StagedModified, StagedUnmerged, DiffAdded, ConflictOurs, RefBranch} {
+		t[r] = foreground(gloss.Color(good))
+	}
+	for _, r := range []Role{StagedDeleted, UnstagedAdded, UnstagedModified, UnstagedDeleted, Untracked, DiffDeleted,
+		Danger, RefRemote} {
+		t[r] = foreground(gloss.Color(bad))
+	}
+	for _, r := range []Role{StagedRenamed, UnstagedRenamed, UnstagedUnmerged, ConflictTheirs,
+		Operation, Ref, Warning, RefTag, TodoEdit} {
+		t[r] = foreground(gloss.Color(moved))
+	}
+	t[Search] = gloss.NewStyle().Background(gloss.Color(moved))
+	return t
+}
+
+func foreground(c gloss.TerminalColor) gloss.Style {
+	return gloss.NewStyle().Foreground(c)
+}
+
+// ThemeNames lists the themes got comes with, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// current is the theme the roles are rendered with.
+var current = Themes["default"]
+
+// Use sets the theme the roles are rendered with.
+func Use(t Theme) {
+	current = t
+}
+
+// Foreground renders s in the style of the role.
+func (r Role) Foreground(s ...string) string {
+	style, ok := current[r]
+	if !ok {
+		return strings.Join(s, "")
+	}
+	return style.Render(s...)
+}
+
+// Style returns the style of the role.
+func (r Role) Style() gloss.Style {
+	if style, ok := current[r]; ok {
+		return style
+	}
+	return gloss.NewStyle()
+}
+
+// statusRoles gives the role for each status code, for staged and unstaged
+// changes.
+var statusRoles = map[bool]map[git.StatusCode]Role{
+	true: {
+		git.Added:              StagedAdded,
+		git.Deleted:            StagedDeleted,
+		git.Modified:           StagedModified,
+		git.Renamed:            StagedRenamed,
+		git.UpdatedButUnmerged: StagedUnmerged,
+	},
+	false: {
+		git.Added:              UnstagedAdded,
+		git.Deleted:            UnstagedDeleted,
+		git.Modified:           UnstagedModified,
+		git.Renamed:            UnstagedRenamed,
+		git.UpdatedButUnmerged: UnstagedUnmerged,
+		git.Untracked:          Untracked,
+	},
+}
@@ -0,0 +1,478 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+// Widths of the columns that follow the branch name.
+const (
+	trackWidth  = 9
+	authorWidth = 16
+	dateWidth   = 14
+)
+
+type branchKeyMap struct {
+	Up       key.Binding
+	Down     key.Binding
+	Checkout key.Binding
+	Create   key.Binding
+	Rename   key.Binding
+	Delete   key.Binding
+	Upstream key.Binding
+	Filter   key.Binding
+	Quit     key.Binding
+}
+
+func (k branchKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Quit}
+}
+
+func (k branchKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Checkout, k.Create},
+		{k.Rename, k.Delete},
+		{k.Upstream, k.Filter},
+		{k.Quit},
+	}
+}
+
+var branchKeys = branchKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "down   "),
+	),
+	Checkout: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("ent", "checkout   "),
+	),
+	Create: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "new   "),
+	),
+	Rename: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "rename   "),
+	),
+	Delete: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "delete   "),
+	),
+	Upstream: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "upstream   "),
+	),
+	Filter: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "filter   "),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	),
+}
+
+// branchMatch is a branch that passes the filter, with the positions of the
+// matched characters in its name.
+type branchMatch struct {
+	index     int
+	positions []int
+}
+
+// branchModel lists the local and remote-tracking branches. The cursor moves
+// over the branches that pass the filter.
+type branchModel struct {
+	frame
+	term      dimensions
+	keys      branchKeyMap
+	head      head
+	branches  []git.Branch
+	visible   []branchMatch
+	selected  int
+	filter    textinput.Model
+	filtering bool
+	message   string
+}
+
+func Branch(state git.RepoState, args []string) error {
+	flags("branch", args)
+
+	branches, err := git.Branches()
+	if err != nil {
+		return err
+	}
+
+	filter := textinput.New()
+	filter.Prompt = "/"
+	model := branchModel{
+		frame:    newFrame(),
+		keys:     branchKeys,
+		head:     newHead(state),
+		branches: branches,
+		filter:   filter,
+	}
+	model.applyFilter()
+	for i, v := range model.visible {
+		if model.branches[v.index].Current {
+			model.selected = i
+		}
+	}
+
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		log.Fatalf("Fatal error: %v", err)
+	}
+	return nil
+}
+
+func (m branchModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m branchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.message = ""
+		switch {
+		case m.prompt != promptNone:
+			return m.updatePrompt(msg)
+		case m.filtering:
+			return m.updateFilter(msg)
+		case key.Matches(msg, m.promptKeys.Cancel) && m.filter.Value() != "":
+			// the first esc clears the filter rather than quitting
+			m.clearFilter()
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Up):
+			m.move(-1)
+		case key.Matches(msg, m.keys.Down):
+			m.move(1)
+		case key.Matches(msg, m.keys.Filter):
+			m.filtering = true
+			cmd = m.filter.Focus()
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		}
+
+		current, ok := m.current()
+		if !ok {
+			break
+		}
+		switch {
+		case key.Matches(msg, m.keys.Checkout):
+			if err := git.Checkout(current); err != nil {
+				m.keep(err, current.Name)
+				break
+			}
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Create):
+			cmd = m.ask(promptCreate, "")
+		case key.Matches(msg, m.keys.Rename):
+			if current.Remote {
+				m.message = "remote-tracking branches cannot be renamed"
+				break
+			}
+			cmd = m.ask(promptRename, current.Name)
+		case key.Matches(msg, m.keys.Delete):
+			if current.Remote {
+				m.message = "remote-tracking branches are deleted on the remote, with git push --delete"
+				break
+			}
+			err := git.DeleteBranch(current.Name, false)
+			if errors.Is(err, git.ErrNotMerged) {
+				m.prompt = promptForceDelete
+				break
+			}
+			m.keep(err, current.Name)
+		case key.Matches(msg, m.keys.Upstream):
+			if current.Remote {
+				m.message = "remote-tracking branches have no upstream"
+				break
+			}
+			upstream := current.Tracking.Upstream
+			if upstream == "" {
+				upstream = "origin/" + current.Name
+			}
+			cmd = m.ask(promptUpstream, upstream)
+		}
+
+	case tea.WindowSizeMsg:
+		m.term.width = msg.Width
+		m.term.height = msg.Height
+		m.resize()
+
+	default:
+		// keep the cursor of the prompt or the filter blinking
+		switch {
+		case m.prompt != promptNone:
+			m.input, cmd = m.input.Update(msg)
+		case m.filtering:
+			m.filter, cmd = m.filter.Update(msg)
+		}
+	}
+
+	return m, cmd
+}
+
+// current returns the branch under the cursor, if any passes the filter.
+func (m branchModel) current() (git.Branch, bool) {
+	if len(m.visible) == 0 {
+		return git.Branch{}, false
+	}
+	return m.branches[m.visible[m.selected].index], true
+}
+
+func (m *branchModel) move(delta int) {
+	if len(m.visible) == 0 {
+		return
+	}
+	m.selected = (m.selected + len(m.visible) + delta) % len(m.visible)
+}
+
+func (m branchModel) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.promptKeys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, m.promptKeys.Cancel):
+		m.clearFilter()
+		return m, nil
+	case key.Matches(msg, m.promptKeys.Submit):
+		m.filtering = false
+		m.filter.Blur()
+		return m, nil
+	case msg.Type == tea.KeyUp:
+		m.move(-1)
+		return m, nil
+	case msg.Type == tea.KeyDown:
+		m.move(1)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	m.applyFilter()
+	return m, cmd
+}
+
+func (m *branchModel) clearFilter() {
+	m.filtering = false
+	m.filter.Blur()
+
+	current, ok := m.current()
+	m.filter.Reset()
+	m.applyFilter()
+	if ok {
+		m.selectBranch(current.Name, current.Remote)
+	}
+}
+
+// applyFilter collects the branches that match the filter, keeping the cursor
+// within the list.
+func (m *branchModel) applyFilter() {
+	m.visible = m.visible[:0]
+	for i, b := range m.branches {
+		if positions, ok := fuzzyMatch(m.filter.Value(), b.Name); ok {
+			m.visible = append(m.visible, branchMatch{index: i, positions: positions})
+		}
+	}
+	m.selected = min(m.selected, max(0, len(m.visible)-1))
+}
+
+func (m *branchModel) selectBranch(name string, remote bool) {
+	for i, v := range m.visible {
+		if b := m.branches[v.index]; b.Remote == remote && b.Name == name {
+			m.selected = i
+			return
+		}
+	}
+}
+
+func (m branchModel) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	current, _ := m.current()
+
+	p, value, cmd := m.answer(msg)
+	switch p {
+	case promptForceDelete:
+		m.keep(git.DeleteBranch(current.Name, true), current.Name)
+	case promptCreate:
+		m.keep(git.CreateBranch(value, current.Name), value)
+	case promptRename:
+		m.keep(git.RenameBranch(current.Name, value), value)
+	case promptUpstream:
+		m.keep(git.SetUpstream(current.Name, value), current.Name)
+	}
+	return m, cmd
+}
+
+// keep reloads the branches after an action that stays in the view, moving the
+// cursor to the named local branch if it is still there, and shows the error
+// of the action if there is one.
+func (m *branchModel) keep(err error, name string) {
+	if err != nil {
+		m.fail(err)
+	}
+
+	branches, err := git.Branches()
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	m.branches = branches
+	m.applyFilter()
+	m.selectBranch(name, false)
+}
+
+func (m *branchModel) fail(err error) {
+	m.message = err.Error()
+}
+
+func (m *branchModel) resize() {
+	if m.term.width == 0 {
+		return
+	}
+
+	list := dimensions{width: capWidth(m.term.width), height: m.term.height}
+	m.fit(list, gloss.Height(m.viewHeader()), gloss.Height(m.viewFooter()), 20)
+	m.filter.Width = max(1, m.viewport.Width/2)
+}
+
+func (m branchModel) View() string {
+	if s, ok := m.tooSmall(m.keys.Quit); ok {
+		return s
+	}
+
+	content, line := m.viewContent()
+	m.viewport.SetContent(content)
+	m.keepInView(line)
+
+	return renderFrame(m.xy.height, m.viewHeader(), m.viewport, m.viewFooter())
+}
+
+func (m branchModel) viewHeader() string {
+	subtitle := fmt.Sprintf("%d branches", len(m.branches))
+	switch {
+	case m.filtering:
+		subtitle = m.filter.View()
+	case m.filter.Value() != "":
+		subtitle = fmt.Sprintf("/%s %d/%d", m.filter.Value(), len(m.visible), len(m.branches))
+	}
+	return renderHeader(m.viewport.Width, m.head.title(), subtitle)
+}
+
+func (m branchModel) viewFooter() string {
+	return renderFooter(m.viewport.Width, m.help, m.keys, m.message)
+}
+
+// viewContent renders the list and returns it along with the line the cursor
+// is on.
+func (m branchModel) viewContent() (string, int) {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+	nameWidth := max(10, contentWidth-3-trackWidth-authorWidth-dateWidth-3)
+
+	var (
+		out      strings.Builder
+		lines    int
+		selected int
+		section  string
+	)
+	if len(m.visible) == 0 {
+		out.WriteString("\nno branches match the filter\n")
+	}
+	for i, v := range m.visible {
+		b := m.branches[v.index]
+
+		s := "Local"
+		if b.Remote {
+			s = "Remote"
+		}
+		if s != section {
+			section = s
+			out.WriteString(renderSeparator(m.viewport, s))
+			lines++
+		}
+
+		cursor := "   "
+		if i == m.selected {
+			cursor = color.Cursor.Foreground(" ◈ ")
+			selected = lines
+		}
+
+		plain := gloss.NewStyle().Render
+		switch {
+		case b.Current:
+			plain = color.RefBranch.Foreground
+		case b.Remote:
+			plain = color.RefRemote.Foreground
+		}
+		name := b.Name
+		if gloss.Width(name) > nameWidth {
+			name = gloss.NewStyle().MaxWidth(nameWidth-1).Render(name) + "…"
+		}
+		name = highlight(name, v.positions, color.Match.Foreground, plain)
+
+		out.WriteString(cursor +
+			name + strings.Repeat(" ", max(0, nameWidth-gloss.Width(name))) + " " +
+			column(track(b.Tracking), trackWidth, color.Upstream.Foreground) + " " +
+			column(b.Author, authorWidth, color.Date.Foreground) + " " +
+			column(relativeTime(b.Date), dateWidth, color.Date.Foreground) + "\n")
+		lines++
+	}
+
+	if m.prompt != promptNone {
+		current, _ := m.current()
+		out.WriteString("\n")
+		switch m.prompt {
+		case promptCreate:
+			out.WriteString("New branch at " + current.Name + ": " + m.input.View())
+		case promptRename:
+			out.WriteString("Rename " + current.Name + ": " + m.input.View())
+		case promptUpstream:
+			out.WriteString("Upstream of " + current.Name + ": " + m.input.View())
+		case promptForceDelete:
+			out.WriteString(color.Danger.Foreground(current.Name+" is not fully merged. Delete anyway?") + " " + m.promptKeys.choices())
+		}
+		out.WriteString("\n")
+	}
+
+	return out.String(), selected
+}
+
+// track describes how a branch relates to its upstream, e.g. "2▲ ▼1".
+func track(t git.Tracking) string {
+	if t.Gone {
+		return "gone"
+	}
+	parts := make([]string, 0, 2)
+	if t.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("%d▲", t.Ahead))
+	}
+	if t.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("▼%d", t.Behind))
+	}
+	return strings.Join(parts, " ")
+}
+
+// column pads or truncates s to width and renders it with style.
+func column(s string, width int, style func(...string) string) string {
+	if gloss.Width(s) > width {
+		s = gloss.NewStyle().MaxWidth(width-1).Render(s) + "…"
+	}
+	return style(s + strings.Repeat(" ", max(0, width-gloss.Width(s))))
+}
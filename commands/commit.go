@@ -0,0 +1,331 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+// Subjects longer than subjectLimit get a warning, and longer than
+// subjectHardLimit an error, following the usual conventions for commit
+// messages.
+const (
+	subjectLimit     = 50
+	subjectHardLimit = 72
+)
+
+type commitKeyMap struct {
+	Submit   key.Binding
+	Amend    key.Binding
+	Signoff  key.Binding
+	NoVerify key.Binding
+	Editor   key.Binding
+	Back     key.Binding
+	Quit     key.Binding
+}
+
+func (k commitKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Back}
+}
+
+func (k commitKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Submit, k.Back},
+		{k.Amend, k.Signoff},
+		{k.NoVerify, k.Editor},
+	}
+}
+
+var commitKeys = commitKeyMap{
+	Submit: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "commit   "),
+	),
+	Amend: key.NewBinding(
+		key.WithKeys("alt+a"),
+		key.WithHelp("alt+a", "amend   "),
+	),
+	Signoff: key.NewBinding(
+		key.WithKeys("alt+s"),
+		key.WithHelp("alt+s", "signoff   "),
+	),
+	NoVerify: key.NewBinding(
+		key.WithKeys("alt+v"),
+		key.WithHelp("alt+v", "no-verify"),
+	),
+	Editor: key.NewBinding(
+		key.WithKeys("alt+e"),
+		key.WithHelp("alt+e", "editor"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "back   "),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+	),
+}
+
+// commitView holds the state of the commit message editor. The message is kept
+// when leaving the editor, so it can be picked up again later in the session.
+type commitView struct {
+	editor  textarea.Model
+	started bool
+	options git.CommitOptions
+	// amended is the message that was filled in when amend was switched on
+	amended string
+	// output holds whatever git printed when the last commit attempt failed,
+	// which includes the output of hooks
+	output string
+}
+
+// editorMsg is sent when the external editor exits.
+type editorMsg struct {
+	path string
+	err  error
+}
+
+func (m *model) openCommit() tea.Cmd {
+	if !m.commit.started {
+		editor := textarea.New()
+		editor.CharLimit = 0
+		editor.ShowLineNumbers = false
+		editor.Placeholder = "Commit message"
+		editor.FocusedStyle.CursorLine = gloss.NewStyle()
+		m.commit.editor = editor
+		m.commit.started = true
+	}
+
+	m.mode = modeCommit
+	m.resize()
+	return m.commit.editor.Focus()
+}
+
+func (m *model) closeCommit() {
+	m.commit.editor.Blur()
+	m.mode = modeFiles
+	m.resize()
+}
+
+// resizeCommit fits the editor into the viewport, leaving room for the option
+// and guidance lines and any output of a failed commit.
+func (m *model) resizeCommit() {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+	m.commit.editor.SetWidth(contentWidth)
+	m.commit.editor.SetHeight(max(3, m.viewport.Height-4-len(m.commitOutput())))
+}
+
+func (m model) updateCommit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, m.commitKeys.Back):
+		m.closeCommit()
+	case key.Matches(msg, m.commitKeys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, m.commitKeys.Amend):
+		m.toggleAmend()
+	case key.Matches(msg, m.commitKeys.Signoff):
+		m.commit.options.Signoff = !m.commit.options.Signoff
+	case key.Matches(msg, m.commitKeys.NoVerify):
+		m.commit.options.NoVerify = !m.commit.options.NoVerify
+	case key.Matches(msg, m.commitKeys.Editor):
+		cmd = m.openEditor()
+	case key.Matches(msg, m.commitKeys.Submit):
+		return m.submitCommit()
+	default:
+		m.commit.editor, cmd = m.commit.editor.Update(msg)
+	}
+
+	return m, cmd
+}
+
+// toggleAmend switches amending on or off. Amending with an empty message
+// starts from the message of the commit being amended.
+func (m *model) toggleAmend() {
+	c := &m.commit
+	c.options.Amend = !c.options.Amend
+
+	switch {
+	case c.options.Amend && strings.TrimSpace(c.editor.Value()) == "":
+		message, err := m.repo.LastCommitMessage()
+		if err != nil {
+			m.fail(err)
+			return
+		}
+		c.amended = strings.TrimRight(message, "\n")
+		c.editor.SetValue(c.amended)
+	case !c.options.Amend && c.editor.Value() == c.amended:
+		c.editor.Reset()
+		c.amended = ""
+	}
+}
+
+// submitCommit commits, showing the pending actions for review first if they
+// need it.
+func (m model) submitCommit() (tea.Model, tea.Cmd) {
+	if m.needsReview() {
+		m.openReview(reviewCommit)
+		return m, nil
+	}
+	return m.commitPending()
+}
+
+// commitPending applies the pending actions and then commits. On failure the
+// editor stays open with the output of git below it.
+func (m model) commitPending() (tea.Model, tea.Cmd) {
+	if err := m.process(m.files); err != nil {
+		m.fail(err)
+		if err := m.reload(); err != nil {
+			m.fail(err)
+		}
+		return m, nil
+	}
+
+	options := m.commit.options
+	options.Message = m.commit.editor.Value()
+	err := m.repo.Commit(options)
+	if err == nil {
+		return m, tea.Quit
+	}
+
+	// pending actions have been applied, so they must not be applied again
+	if err := m.reload(); err != nil {
+		m.fail(err)
+	}
+
+	var gitErr *git.Error
+	if errors.As(err, &gitErr) && strings.TrimSpace(gitErr.Stderr) != "" {
+		m.commit.output = strings.TrimSpace(gitErr.Stderr)
+	} else {
+		m.commit.output = err.Error()
+	}
+	m.resize()
+	return m, nil
+}
+
+// openEditor hands the message over to the editor configured for git.
+func (m *model) openEditor() tea.Cmd {
+	editor, err := git.Editor()
+	if err != nil {
+		m.fail(err)
+		return nil
+	}
+
+	f, err := os.CreateTemp("", "got-COMMIT_EDITMSG-*")
+	if err != nil {
+		m.fail(err)
+		return nil
+	}
+	_, err = f.WriteString(m.commit.editor.Value() + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		m.fail(err)
+		return nil
+	}
+
+	// the editor is a shell snippet, which is how git runs it as well
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, f.Name())
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorMsg{path: f.Name(), err: err}
+	})
+}
+
+func (m *model) closeEditor(msg editorMsg) {
+	defer os.Remove(msg.path)
+
+	if msg.err != nil {
+		m.fail(msg.err)
+		return
+	}
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	m.commit.editor.SetValue(strings.TrimRight(string(data), "\n"))
+}
+
+// commitOutput returns the lines of output of the last failed commit, limited
+// to a third of the viewport.
+func (m model) commitOutput() []string {
+	if m.commit.output == "" {
+		return nil
+	}
+	lines := strings.Split(m.commit.output, "\n")
+	if limit := max(1, m.viewport.Height/3); len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines
+}
+
+func (m model) viewCommit() string {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+
+	title := "Commit"
+	if m.commit.options.Amend {
+		title = "Amend"
+	}
+
+	var out strings.Builder
+	out.WriteString(m.getContentSeparator(title))
+
+	checkbox := func(label string, on bool) string {
+		if on {
+			return color.Checked.Foreground("[x] " + label)
+		}
+		return color.Unchecked.Foreground("[ ] " + label)
+	}
+	out.WriteString(strings.Join([]string{
+		checkbox("amend", m.commit.options.Amend),
+		checkbox("signoff", m.commit.options.Signoff),
+		checkbox("no-verify", m.commit.options.NoVerify),
+	}, "  ") + "\n")
+
+	out.WriteString(m.commit.editor.View() + "\n")
+	out.WriteString(m.commit.guidance())
+	if summary := m.pendingSummary(); summary != "" {
+		out.WriteString(color.Note.Foreground(" · " + summary))
+	}
+	out.WriteString("\n")
+
+	truncate := gloss.NewStyle().MaxWidth(contentWidth).Render
+	for _, l := range m.commitOutput() {
+		out.WriteString(color.Message.Foreground(truncate(l)) + "\n")
+	}
+
+	return out.String()
+}
+
+// guidance returns hints on the shape of the message: a short subject,
+// separated from the body by a blank line.
+func (c commitView) guidance() string {
+	lines := strings.Split(c.editor.Value(), "\n")
+
+	subject := len([]rune(lines[0]))
+	text := fmt.Sprintf("subject %d/%d", subject, subjectLimit)
+	switch {
+	case subject > subjectHardLimit:
+		text = color.Danger.Foreground(text)
+	case subject > subjectLimit:
+		text = color.Warning.Foreground(text)
+	default:
+		text = color.Note.Foreground(text)
+	}
+
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		text += color.Warning.Foreground(" · add a blank line after the subject")
+	}
+	return text
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/config"
+)
+
+// settings are those passed to Configure, or the defaults until it is called.
+var settings = config.Default()
+
+// Configure sets the settings the commands run with, and binds the keys as
+// configured. It is called once, before any command is run.
+func Configure(c config.Config) error {
+	settings = c
+	color.SetMode(c.Color)
+	color.Use(c.Colors)
+	return configureKeys(c)
+}
+
+// capWidth limits a width to the width set for the views.
+func capWidth(width int) int {
+	if settings.Width == 0 {
+		return width
+	}
+	return min(settings.Width, width)
+}
@@ -0,0 +1,327 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+type conflictKeyMap struct {
+	Up     key.Binding
+	Down   key.Binding
+	Ours   key.Binding
+	Theirs key.Binding
+	Both   key.Binding
+	Back   key.Binding
+	Quit   key.Binding
+}
+
+func (k conflictKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Back}
+}
+
+func (k conflictKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Ours},
+		{k.Theirs},
+		{k.Both},
+		{k.Back},
+	}
+}
+
+var conflictKeys = conflictKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "down   "),
+	),
+	Ours: key.NewBinding(
+		key.WithKeys("o", "left", "h"),
+		key.WithHelp("o", "ours   "),
+	),
+	Theirs: key.NewBinding(
+		key.WithKeys("t", "right", "l"),
+		key.WithHelp("t", "theirs   "),
+	),
+	Both: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "both   "),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("q", "esc"),
+		key.WithHelp("q", "back"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+	),
+}
+
+// conflictContext is the number of lines shown around each conflict.
+const conflictContext = 3
+
+// conflictView holds the state of the conflict screen for a single file. The
+// cursor counts the conflicts that are left, so that it stays in place when
+// the file is read again.
+type conflictView struct {
+	file    file
+	chunks  []git.Chunk
+	current int
+}
+
+// conflicted returns the indices of the chunks that are still conflicts.
+func (v conflictView) conflicted() []int {
+	var idx []int
+	for i, c := range v.chunks {
+		if c.Conflict {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// chunk returns the index of the chunk under the cursor.
+func (v conflictView) chunk() int {
+	return v.conflicted()[v.current]
+}
+
+// move steps the cursor to the previous or next conflict.
+func (v *conflictView) move(delta int) {
+	n := len(v.conflicted())
+	v.current = (v.current + delta + n) % n
+}
+
+// update replaces the chunks of the file, keeping the cursor on the conflict
+// with the same number or the last one. It reports whether any are left.
+func (v *conflictView) update(chunks []git.Chunk) bool {
+	v.chunks = chunks
+	n := len(v.conflicted())
+	v.current = max(0, min(v.current, n-1))
+	return n > 0
+}
+
+func readConflict(path string) ([]git.Chunk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return git.ParseMarkers(string(data)), nil
+}
+
+func (m *model) openConflict() {
+	f := m.files[m.selected]
+
+	chunks, err := readConflict(m.rootdir + "/" + f.path)
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	v := conflictView{file: f}
+	if !v.update(chunks) {
+		m.fail(fmt.Errorf("no conflict markers in %s", f.path))
+		return
+	}
+
+	m.mode = modeConflict
+	m.conflicts = v
+	m.resize()
+
+	m.viewport.SetContent(m.viewContent())
+	m.viewport.GotoTop()
+}
+
+// closeConflict goes back to the file list, marking the file as resolved if
+// no conflicts are left in it.
+func (m *model) closeConflict() {
+	if len(m.conflicts.conflicted()) == 0 {
+		clear(m.conflicts.file.pending)
+		m.conflicts.file.pending[resolve] = true
+	}
+	m.mode = modeFiles
+	if err := m.reload(); err != nil {
+		m.fail(err)
+	}
+	m.resize()
+}
+
+// take resolves the conflict under the cursor with the lines of one or both
+// sides, and writes the file right away.
+func (m *model) take(sides ...git.Side) {
+	v := &m.conflicts
+	c := v.chunks[v.chunk()]
+
+	var lines []string
+	for _, s := range sides {
+		if s == git.Ours {
+			lines = append(lines, c.Ours...)
+		} else {
+			lines = append(lines, c.Theirs...)
+		}
+	}
+	chunks := append([]git.Chunk(nil), v.chunks...)
+	chunks[v.chunk()] = git.Chunk{Lines: lines}
+	data := git.JoinChunks(chunks)
+
+	path := m.rootdir + "/" + v.file.path
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	// the file may hold edits of its own besides the markers
+	if err := m.repo.Snapshot("resolve a conflict in "+v.file.path, path); err != nil {
+		m.fail(err)
+		return
+	}
+	if err := os.WriteFile(path, []byte(data), perm); err != nil {
+		m.fail(err)
+		return
+	}
+
+	// parse the file again so the chunks match those read on a refresh
+	if !v.update(git.ParseMarkers(data)) {
+		m.closeConflict()
+	}
+}
+
+// refreshConflict re-reads the file after it changed, e.g. in an editor, and
+// closes the view if its conflicts are gone.
+func (m *model) refreshConflict() {
+	v := &m.conflicts
+
+	chunks, err := readConflict(m.rootdir + "/" + v.file.path)
+	if err != nil {
+		m.fail(err)
+		m.mode = modeFiles
+		m.resize()
+		return
+	}
+	if !v.update(chunks) {
+		m.closeConflict()
+	}
+}
+
+func (m model) updateConflict(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.conflictKeys.Up):
+		m.conflicts.move(-1)
+	case key.Matches(msg, m.conflictKeys.Down):
+		m.conflicts.move(1)
+	case key.Matches(msg, m.conflictKeys.Ours):
+		m.take(git.Ours)
+	case key.Matches(msg, m.conflictKeys.Theirs):
+		m.take(git.Theirs)
+	case key.Matches(msg, m.conflictKeys.Both):
+		m.take(git.Ours, git.Theirs)
+	case key.Matches(msg, m.conflictKeys.Back):
+		m.closeConflict()
+		return m, nil
+	case key.Matches(msg, m.conflictKeys.Quit):
+		return m, tea.Quit
+	}
+
+	if m.mode == modeConflict {
+		m.viewport.SetContent(m.viewContent())
+		m.scrollConflict()
+	}
+	return m, nil
+}
+
+// scrollConflict keeps as much of the conflict under the cursor as fits within
+// the viewport.
+func (m *model) scrollConflict() {
+	_, top, bottom := m.conflictLines()
+	if bottom >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(bottom - m.viewport.Height + 1)
+	}
+	if top < m.viewport.YOffset {
+		m.viewport.SetYOffset(top)
+	}
+}
+
+// conflictLines renders the conflicts of the file with a few lines of context
+// around each, and returns the first and last line of the one under the cursor.
+func (m model) conflictLines() ([]string, int, int) {
+	v := m.conflicts
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+	truncate := gloss.NewStyle().MaxWidth(contentWidth - 2).Render
+	text := func(l string) string {
+		return truncate(strings.ReplaceAll(strings.TrimRight(l, "\r\n"), "\t", "    "))
+	}
+
+	title := v.file.conflict.Code() + " " + v.file.path
+	if gloss.Width(title) > contentWidth-16 {
+		title = "…" + title[max(0, gloss.Width(title)-contentWidth+16):]
+	}
+	out := strings.Split(strings.TrimSuffix(m.getContentSeparator(title), "\n"), "\n")
+
+	cur := v.chunk()
+	var top, bottom int
+	for i, c := range v.chunks {
+		if !c.Conflict {
+			lines := c.Lines
+			switch {
+			case i == 0 && len(lines) > conflictContext:
+				lines = lines[len(lines)-conflictContext:]
+			case i == len(v.chunks)-1 && len(lines) > conflictContext:
+				lines = lines[:conflictContext]
+			case i > 0 && i < len(v.chunks)-1 && len(lines) > 2*conflictContext+1:
+				lines = append(append(lines[:conflictContext:conflictContext], "⋯\n"), lines[len(lines)-conflictContext:]...)
+			}
+			for _, l := range lines {
+				out = append(out, "  "+text(l))
+			}
+			continue
+		}
+
+		current := i == cur
+		if current {
+			top = len(out)
+		}
+		gutter := "  "
+		if current {
+			gutter = color.Marker.Foreground("│ ")
+		}
+		section := func(marker, label string, lines []string, style func(...string) string) {
+			head := gutter
+			if current && marker == "<<<<<<<" {
+				head = color.Cursor.Foreground("◈ ")
+			}
+			out = append(out, head+color.ConflictLabel.Foreground(truncate(strings.TrimSpace(marker+" "+label))))
+			for _, l := range lines {
+				out = append(out, gutter+style(text(l)))
+			}
+		}
+		section("<<<<<<<", sideLabel(c.OursLabel, "ours"), c.Ours, color.ConflictOurs.Foreground)
+		if c.BaseLabel != "" || len(c.Base) > 0 {
+			section("|||||||", sideLabel(c.BaseLabel, "base"), c.Base, color.ConflictBase.Foreground)
+		}
+		section("=======", "", c.Theirs, color.ConflictTheirs.Foreground)
+		out = append(out, gutter+color.ConflictLabel.Foreground(truncate(">>>>>>> "+sideLabel(c.TheirsLabel, "theirs"))))
+		if current {
+			bottom = len(out) - 1
+		}
+	}
+	return out, top, bottom
+}
+
+// sideLabel names a side of a conflict along with its marker label, e.g.
+// "ours (HEAD)".
+func sideLabel(label, side string) string {
+	if label == "" {
+		return side
+	}
+	return side + " (" + label + ")"
+}
+
+func (m model) viewConflict() string {
+	lines, _, _ := m.conflictLines()
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The file list can be narrowed down to the paths that fuzzy match a filter.
+// Files that do not match are only hidden: they keep their pending actions,
+// which still apply on submit, and show up again once the filter is cleared.
+
+// visible reports whether the file at index i passes the filter.
+func (m model) visible(i int) bool {
+	if m.matches == nil {
+		return true
+	}
+	_, ok := m.matches[i]
+	return ok
+}
+
+// step returns the index of the next file that passes the filter in the given
+// direction, wrapping around, or from itself if there is none.
+func (m model) step(from, delta int) int {
+	n := len(m.files)
+	for i := 1; i <= n; i++ {
+		j := ((from+delta*i)%n + n) % n
+		if m.visible(j) {
+			return j
+		}
+	}
+	return from
+}
+
+// applyFilter matches the paths of the files against the filter, and moves the
+// cursor off a file that no longer passes it.
+func (m *model) applyFilter() {
+	m.matches = nil
+	if query := m.filter.Value(); query != "" {
+		m.matches = make(map[int][]int)
+		for i, f := range m.files {
+			if positions, ok := fuzzyMatch(query, f.path); ok {
+				m.matches[i] = positions
+			}
+		}
+	}
+	m.keys.Next.SetEnabled(m.matches != nil)
+	m.keys.Prev.SetEnabled(m.matches != nil)
+
+	if len(m.files) > 0 && !m.visible(m.selected) {
+		m.selected = m.step(m.selected, 1)
+	}
+	m.settle()
+}
+
+func (m model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.promptKeys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, m.promptKeys.Cancel):
+		m.clearFilter()
+		return m, nil
+	case key.Matches(msg, m.promptKeys.Submit):
+		m.filtering = false
+		m.filter.Blur()
+		return m, nil
+	case msg.Type == tea.KeyUp:
+		m.move(-1)
+		return m, nil
+	case msg.Type == tea.KeyDown:
+		m.move(1)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	m.applyFilter()
+	m.scroll()
+	return m, cmd
+}
+
+func (m *model) clearFilter() {
+	m.filtering = false
+	m.filter.Blur()
+	m.filter.Reset()
+	m.applyFilter()
+	m.scroll()
+}
+
+// filterStatus shows the filter while it is typed, or what it matched after.
+func (m model) filterStatus() string {
+	switch {
+	case m.filtering:
+		return m.filter.View()
+	case m.matches != nil:
+		return fmt.Sprintf("/%s %d/%d", m.filter.Value(), len(m.matches), len(m.files))
+	}
+	return ""
+}
@@ -0,0 +1,312 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+)
+
+// The views of all commands are drawn in the same frame: a header with a title
+// and a subtitle, the viewport with a scrollbar on either side, and a footer
+// with the key help.
+
+// prompt is the question a view is waiting on an answer to, if any. The answer
+// is either yes or no, or a line of text.
+type prompt byte
+
+const (
+	promptNone prompt = iota
+	promptDrop
+	promptRename
+	promptBranch
+	promptCreate
+	promptForceDelete
+	promptUpstream
+	promptCherryPick
+	promptRevert
+	promptAbort
+	promptExec
+	promptGlob
+)
+
+// promptKeyMap holds the keys that answer the prompts of all views, and that
+// finish the filter and the search.
+type promptKeyMap struct {
+	Yes    key.Binding
+	No     key.Binding
+	Submit key.Binding
+	Cancel key.Binding
+	Quit   key.Binding
+}
+
+var promptKeys = promptKeyMap{
+	Yes: key.NewBinding(
+		key.WithKeys("y"),
+	),
+	No: key.NewBinding(
+		key.WithKeys("n"),
+	),
+	Submit: key.NewBinding(
+		key.WithKeys("enter"),
+	),
+	Cancel: key.NewBinding(
+		key.WithKeys("esc"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+	),
+}
+
+// choices shows the keys that answer a yes/no prompt, e.g. "y/n".
+func (k promptKeyMap) choices() string {
+	return helpKey(k.Yes.Keys(), k.No.Keys())
+}
+
+// yesNo reports whether the prompt is answered with yes or no rather than a
+// line of text.
+func (p prompt) yesNo() bool {
+	switch p {
+	case promptDrop, promptForceDelete, promptCherryPick, promptRevert, promptAbort:
+		return true
+	}
+	return false
+}
+
+// frame is the state the views share: the size of the list, the viewport and
+// the key help around it, and the prompt below it. The views embed it.
+type frame struct {
+	xy         dimensions
+	viewport   viewport.Model
+	help       help.Model
+	ready      bool
+	prompt     prompt
+	input      textinput.Model
+	promptKeys promptKeyMap
+}
+
+func newFrame() frame {
+	input := textinput.New()
+	input.Prompt = ""
+	return frame{help: newHelp(), input: input, promptKeys: promptKeys}
+}
+
+// fit sizes the viewport to the list, leaving room for the header and the
+// footer, and the input to what the text of the prompt leaves of a line. The
+// viewport is created the first time.
+func (f *frame) fit(list dimensions, headerHeight, footerHeight, promptWidth int) {
+	f.xy = list
+	f.help.Width = list.width - 10
+	if !f.ready {
+		f.viewport = viewport.New(list.width-4, list.height-headerHeight-footerHeight)
+		f.viewport.Style = f.viewport.Style.Padding(0, 2)
+		f.ready = true
+	} else {
+		f.viewport.Width = list.width - 4
+		f.viewport.Height = list.height - headerHeight - footerHeight
+	}
+	f.input.Width = max(1, f.viewport.Width-f.viewport.Style.GetHorizontalPadding()-promptWidth)
+}
+
+// tooSmall returns what is shown instead of the frame when the terminal cannot
+// fit it, naming the keys that quit the view.
+func (f frame) tooSmall(quit key.Binding) (string, bool) {
+	if f.xy.width >= 40 && f.xy.height >= 10 {
+		return "", false
+	}
+	text := "Your terminal is too small.\nResize the terminal to proceed"
+	if keys := quit.Keys(); len(keys) > 0 {
+		text += "\nor press " + strings.Join(keys, "/") + " to exit"
+	}
+	return gloss.NewStyle().Width(f.xy.width).Height(f.xy.height).Align(gloss.Center, gloss.Center).Render(text + "."), true
+}
+
+// keepInView scrolls the viewport to the given line of its content, or to the
+// prompt below the list while one is open.
+func (f *frame) keepInView(line int) {
+	switch {
+	case f.prompt != promptNone:
+		f.viewport.GotoBottom()
+	case line < f.viewport.YOffset:
+		f.viewport.SetYOffset(line)
+	case line >= f.viewport.YOffset+f.viewport.Height:
+		f.viewport.SetYOffset(line - f.viewport.Height + 1)
+	}
+}
+
+// ask opens a prompt for a line of text, starting out with value.
+func (f *frame) ask(p prompt, value string) tea.Cmd {
+	f.prompt = p
+	f.input.SetValue(value)
+	f.input.CursorEnd()
+	return f.input.Focus()
+}
+
+func (f *frame) closePrompt() {
+	f.prompt = promptNone
+	f.input.Blur()
+	f.input.Reset()
+}
+
+// answer handles a key pressed while a prompt is open. Once the prompt is
+// answered with yes, or with a line of text that is not empty, it is closed and
+// returned along with the text. Cancel, and no, close it without an answer.
+func (f *frame) answer(msg tea.KeyMsg) (prompt, string, tea.Cmd) {
+	p := f.prompt
+	switch {
+	case key.Matches(msg, f.promptKeys.Quit):
+		return promptNone, "", tea.Quit
+	case key.Matches(msg, f.promptKeys.Cancel):
+		f.closePrompt()
+		return promptNone, "", nil
+	case p.yesNo():
+		switch {
+		case key.Matches(msg, f.promptKeys.Yes):
+			f.closePrompt()
+			return p, "", nil
+		case key.Matches(msg, f.promptKeys.No):
+			f.closePrompt()
+		}
+		return promptNone, "", nil
+	case !key.Matches(msg, f.promptKeys.Submit):
+		var cmd tea.Cmd
+		f.input, cmd = f.input.Update(msg)
+		return promptNone, "", cmd
+	}
+
+	value := strings.TrimSpace(f.input.Value())
+	if value == "" {
+		return promptNone, "", nil
+	}
+	f.closePrompt()
+	return p, value, nil
+}
+
+// newHelp returns a help model that renders all bindings without styling, so
+// the footer can color it as a whole.
+func newHelp() help.Model {
+	h := help.New()
+	emptyStyle := gloss.NewStyle()
+	h.Styles.FullKey = emptyStyle
+	h.Styles.FullDesc = emptyStyle
+	h.Styles.ShortKey = emptyStyle
+	h.Styles.ShortDesc = emptyStyle
+	h.ShowAll = true
+	return h
+}
+
+// helpColumns lays out the enabled bindings in columns of two, so that the
+// ones that do not apply at the moment leave no gaps.
+func helpColumns(bindings ...key.Binding) [][]key.Binding {
+	enabled := slices.DeleteFunc(bindings, func(b key.Binding) bool {
+		return !b.Enabled()
+	})
+
+	var columns [][]key.Binding
+	for i := 0; i < len(enabled); i += 2 {
+		columns = append(columns, enabled[i:min(i+2, len(enabled))])
+	}
+	return columns
+}
+
+// renderFrame joins the header, viewport and footer and draws the side borders
+// around them.
+func renderFrame(height int, header string, vp viewport.Model, footer string) string {
+	main := fmt.Sprintf("%s\n%s\n%s", header, vp.View(), footer)
+	return gloss.JoinHorizontal(gloss.Center,
+		leftPad.Render(renderSideBorder(height, vp, "╭", "│", "╰")),
+		main,
+		rightPad.Render(renderSideBorder(height, vp, "╮", "│", "╯")))
+}
+
+func renderHeader(width int, titleText, subtitleText string) string {
+	style := headerStyle.Inherit(color.Header.Style())
+	title := style.Render(titleText)
+
+	var subtitle string
+	if subtitleText != "" {
+		subtitle = style.Render(subtitleText)
+	}
+
+	// if title overflows, truncate, reset color, add elipses, and re-render
+	maxTitleWidth := width - gloss.Width(subtitle)
+	if gloss.Width(title) > maxTitleWidth {
+		titleText = titleText[:max(0, maxTitleWidth-2)] + color.White.Foreground("") + "…"
+		title = style.Render(titleText)
+	}
+
+	fill := strings.Repeat("─", max(0, width-gloss.Width(title)-gloss.Width(subtitle)-2))
+	return gloss.JoinHorizontal(gloss.Center, "─", title, fill, subtitle, "─")
+}
+
+func renderSideBorder(height int, vp viewport.Model, top, mid, bot string) string {
+	borderHeight := height - 4
+
+	mid = mid + "\n"
+	// Not enough content to scroll, therefore no scrollbar
+	if vp.TotalLineCount() == vp.VisibleLineCount() {
+		return fmt.Sprintf("%s\n%s%s\n",
+			top,
+			strings.Repeat(mid, max(0, borderHeight)),
+			bot)
+	}
+
+	scrollBar := "█\n█\n"
+	scrollBarHeight := gloss.Height(scrollBar) - 1
+	scrollPercent := vp.ScrollPercent()
+
+	var scroll int
+	if scrollPercent == 0 {
+		scroll = 0
+	} else {
+		scroll = int(math.Floor(scrollPercent * float64(borderHeight-scrollBarHeight)))
+	}
+
+	return fmt.Sprintf("%s\n%s%s%s%s\n",
+		top,
+		strings.Repeat(mid, max(0, scroll)),
+		scrollBar,
+		strings.Repeat(mid, max(0, borderHeight-scroll-scrollBarHeight)),
+		bot)
+}
+
+// renderFooter shows the key help, or the message in its place if there is
+// one.
+func renderFooter(width int, h help.Model, keyMap help.KeyMap, message string) string {
+	helpText := color.Footer.Foreground(h.View(keyMap))
+	if message != "" {
+		// keep the footer as tall as the help so the viewport height stays valid
+		message = strings.Join(strings.Fields(message), " ")
+		helpText = gloss.NewStyle().MaxWidth(max(1, width-10)).Height(gloss.Height(helpText)).Render(color.Message.Foreground(message))
+	}
+	footerContent := headerStyle.Render(helpText)
+
+	footerFill := width - gloss.Width(footerContent)
+	leftFill := int(math.Floor(float64(footerFill) / 2.0))
+	rightFill := width - gloss.Width(footerContent) - leftFill
+
+	return gloss.JoinHorizontal(gloss.Center,
+		strings.Repeat("─", max(1, leftFill)),
+		footerContent,
+		strings.Repeat("─", max(1, rightFill)))
+}
+
+func renderSeparator(vp viewport.Model, s string) string {
+	s = " " + s + " "
+
+	contentWidth := vp.Width - vp.Style.GetHorizontalPadding()
+	fill := contentWidth - 10
+	leftFill := int(math.Floor(float64(fill) / 2.0))
+	rightFill := contentWidth - gloss.Width(s) - leftFill - 2
+
+	separator := "╾" + strings.Repeat("─", leftFill) + s + strings.Repeat("─", rightFill) + "╼"
+	return color.Separator.Foreground(separator) + "\n"
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fuzzyMatch reports whether the characters of pattern appear in s in the same
+// order, ignoring case, and returns the indices of the runes of s that matched.
+// An empty pattern matches everything.
+func fuzzyMatch(pattern, s string) ([]int, bool) {
+	want := []rune(pattern)
+	if len(want) == 0 {
+		return nil, true
+	}
+
+	positions := make([]int, 0, len(want))
+	for i, r := range []rune(s) {
+		if unicode.ToLower(r) == unicode.ToLower(want[len(positions)]) {
+			positions = append(positions, i)
+			if len(positions) == len(want) {
+				return positions, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// highlight renders the runes of s at the given positions with style, and the
+// rest with plain.
+func highlight(s string, positions []int, style, plain func(...string) string) string {
+	if len(positions) == 0 {
+		return plain(s)
+	}
+
+	var (
+		out  strings.Builder
+		run  []rune
+		hit  bool
+		next int
+	)
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if hit {
+			out.WriteString(style(string(run)))
+		} else {
+			out.WriteString(plain(string(run)))
+		}
+		run = run[:0]
+	}
+	for i, r := range []rune(s) {
+		matched := next < len(positions) && positions[next] == i
+		if matched {
+			next++
+		}
+		if matched != hit {
+			flush()
+			hit = matched
+		}
+		run = append(run, r)
+	}
+	flush()
+	return out.String()
+}
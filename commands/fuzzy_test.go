@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern   string
+		s         string
+		positions []int
+		ok        bool
+	}{
+		{"", "main", nil, true},
+		{"mn", "main", []int{0, 3}, true},
+		{"MAIN", "main", []int{0, 1, 2, 3}, true},
+		{"nm", "main", nil, false},
+		{"fb", "feature/büro", []int{0, 8}, true},
+	}
+
+	for _, tt := range tests {
+		positions, ok := fuzzyMatch(tt.pattern, tt.s)
+		if ok != tt.ok || !slices.Equal(positions, tt.positions) {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, %v, want %v, %v", tt.pattern, tt.s, positions, ok, tt.positions, tt.ok)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	t.Parallel()
+
+	mark := func(s ...string) string { return "[" + s[0] + "]" }
+	plain := func(s ...string) string { return s[0] }
+
+	if got := highlight("feature", []int{0, 1, 4}, mark, plain); got != "[fe]at[u]re" {
+		t.Errorf("highlight = %q", got)
+	}
+	if got := highlight("main", nil, mark, plain); got != "main" {
+		t.Errorf("highlight without matches = %q", got)
+	}
+}
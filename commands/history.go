@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"maps"
+)
+
+// fileKey identifies a file of the list across reloads.
+type fileKey struct {
+	category category
+	path     string
+}
+
+// pendingState holds the actions pending on each file at some point.
+type pendingState map[fileKey]map[action]bool
+
+// history keeps the pending actions as they were before each change, so that
+// changes can be undone and redone until they are submitted.
+type history struct {
+	undo []pendingState
+	redo []pendingState
+}
+
+// pendingState copies the actions pending on the files, leaving out files
+// with none.
+func (m model) pendingState() pendingState {
+	state := make(pendingState)
+	for _, f := range m.files {
+		if set := active(f.pending); len(set) > 0 {
+			state[fileKey{f.category, f.path}] = set
+		}
+	}
+	return state
+}
+
+// active copies the actions that are set in pending.
+func active(pending map[action]bool) map[action]bool {
+	set := make(map[action]bool)
+	for a, on := range pending {
+		if on {
+			set[a] = true
+		}
+	}
+	return set
+}
+
+// record adds the state before a key was handled to the history, if the key
+// changed anything.
+func (m *model) record(before pendingState) {
+	if maps.EqualFunc(before, m.pendingState(), maps.Equal) {
+		return
+	}
+	m.history.undo = append(m.history.undo, before)
+	m.history.redo = nil
+}
+
+// undo goes back to the pending actions before the last change, and redo
+// forward again to those after it.
+func (m *model) undo() {
+	m.travel(&m.history.undo, &m.history.redo, "nothing to undo")
+}
+
+func (m *model) redo() {
+	m.travel(&m.history.redo, &m.history.undo, "nothing to redo")
+}
+
+func (m *model) travel(from, to *[]pendingState, empty string) {
+	if len(*from) == 0 {
+		m.message = empty
+		return
+	}
+	state := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, m.pendingState())
+
+	// the maps are shared with the hunk and conflict views, so they are
+	// changed in place
+	moved := false
+	for i, f := range m.files {
+		want := state[fileKey{f.category, f.path}]
+		if maps.Equal(active(f.pending), want) {
+			continue
+		}
+		clear(f.pending)
+		maps.Copy(f.pending, want)
+		// show the first file that changed
+		if !moved && m.visible(i) {
+			m.folder, m.selected = "", i
+			moved = true
+		}
+	}
+	m.settle()
+	m.scroll()
+}
@@ -0,0 +1,313 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+type hunkKeyMap struct {
+	Up    key.Binding
+	Down  key.Binding
+	Left  key.Binding
+	Right key.Binding
+	Lines key.Binding
+	Back  key.Binding
+	Quit  key.Binding
+}
+
+func (k hunkKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Back}
+}
+
+func (k hunkKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Left},
+		{k.Right},
+		{k.Lines},
+		{k.Back},
+	}
+}
+
+var hunkKeys = hunkKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "down   "),
+	),
+	Left: key.NewBinding(
+		key.WithKeys("left", "h"),
+		key.WithHelp("←/h", "discard   "),
+	),
+	Right: key.NewBinding(
+		key.WithKeys("right", "l"),
+		key.WithHelp("→/l", "stage   "),
+	),
+	Lines: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "lines   "),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("q", "esc"),
+		key.WithHelp("q", "back"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+	),
+}
+
+// hunkView holds the state of the hunk selection screen for a single file.
+// The cursor points at a whole hunk, or at a single changed line of it if
+// lines is set.
+type hunkView struct {
+	file  file
+	diff  git.FileDiff
+	hunk  int
+	line  int
+	lines bool
+}
+
+// selection returns the lines of the current hunk that an action applies to,
+// or nil for the whole hunk.
+func (v hunkView) selection() map[int]bool {
+	if !v.lines {
+		return nil
+	}
+	return map[int]bool{v.line: true}
+}
+
+// move steps the cursor to the previous or next hunk, or to the previous or
+// next changed line when selecting individual lines.
+func (v *hunkView) move(delta int) {
+	n := len(v.diff.Hunks)
+	if !v.lines {
+		v.hunk = (v.hunk + delta + n) % n
+		return
+	}
+
+	h, l := v.hunk, v.line
+	for range len(v.diff.Hunks) + len(v.diff.Hunks[h].Lines) {
+		l += delta
+		if l < 0 || l >= len(v.diff.Hunks[h].Lines) {
+			h = (h + delta + n) % n
+			if delta > 0 {
+				l = 0
+			} else {
+				l = len(v.diff.Hunks[h].Lines) - 1
+			}
+		}
+		if v.diff.Hunks[h].Lines[l].Kind != git.Context {
+			break
+		}
+	}
+	v.hunk, v.line = h, l
+}
+
+func (v *hunkView) toggleLines() {
+	v.lines = !v.lines
+	if v.lines {
+		v.line = -1
+		v.move(1)
+	}
+}
+
+func (m *model) openHunks() {
+	f := m.files[m.selected]
+	if f.category == Untracked {
+		return
+	}
+
+	d, err := m.repo.Diff(m.rootdir+"/"+f.path, f.staged)
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	if len(d.Hunks) == 0 {
+		return
+	}
+
+	m.mode = modeHunks
+	m.hunks = hunkView{file: f, diff: d}
+	if f.staged {
+		m.hunkKeys.Left.SetHelp("←/h", "unstage   ")
+	} else {
+		m.hunkKeys.Left.SetHelp("←/h", "discard   ")
+	}
+	m.hunkKeys.Right.SetEnabled(!f.staged)
+	m.resize()
+
+	m.viewport.SetContent(m.viewContent())
+	m.viewport.GotoTop()
+}
+
+func (m *model) closeHunks() {
+	m.mode = modeFiles
+	if err := m.reload(); err != nil {
+		m.fail(err)
+	}
+	m.resize()
+}
+
+// applyHunk stages, unstages or discards the selection under the cursor and
+// re-reads the diff of the file afterwards.
+func (m *model) applyHunk(left bool) {
+	v := &m.hunks
+	path := m.rootdir + "/" + v.file.path
+
+	var err error
+	switch {
+	case !left && !v.file.staged:
+		err = m.repo.Apply(v.diff.Patch(v.hunk, v.selection(), false), true, false)
+	case left && v.file.staged:
+		// the worktree may have changed the lines since they were staged
+		if m.changedInWorktree(v.file.path) {
+			err = m.repo.SnapshotStaged("unstage part of "+v.file.path, path)
+		}
+		if err == nil {
+			err = m.repo.Apply(v.diff.Patch(v.hunk, v.selection(), true), true, true)
+		}
+	case left:
+		err = m.repo.Snapshot("discard part of "+v.file.path, path)
+		if err == nil {
+			err = m.repo.Apply(v.diff.Patch(v.hunk, v.selection(), true), false, true)
+		}
+	default:
+		return
+	}
+	if err != nil {
+		m.fail(err)
+		return
+	}
+
+	m.refreshHunks()
+}
+
+// refreshHunks re-reads the diff of the file, keeping the cursor in place as
+// far as possible. The view is closed once no hunks are left.
+func (m *model) refreshHunks() {
+	v := &m.hunks
+
+	var err error
+	v.diff, err = m.repo.Diff(m.rootdir+"/"+v.file.path, v.file.staged)
+	if err != nil {
+		m.fail(err)
+		m.closeHunks()
+		return
+	}
+	if len(v.diff.Hunks) == 0 {
+		m.closeHunks()
+		return
+	}
+
+	v.hunk = min(v.hunk, len(v.diff.Hunks)-1)
+	if v.lines {
+		v.line = min(v.line, len(v.diff.Hunks[v.hunk].Lines)-1)
+		if v.diff.Hunks[v.hunk].Lines[v.line].Kind == git.Context {
+			v.move(1)
+		}
+	}
+}
+
+func (m model) updateHunks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.hunkKeys.Up):
+		m.hunks.move(-1)
+	case key.Matches(msg, m.hunkKeys.Down):
+		m.hunks.move(1)
+	case key.Matches(msg, m.hunkKeys.Lines):
+		m.hunks.toggleLines()
+	case key.Matches(msg, m.hunkKeys.Left):
+		m.applyHunk(true)
+	case key.Matches(msg, m.hunkKeys.Right):
+		m.applyHunk(false)
+	case key.Matches(msg, m.hunkKeys.Back):
+		m.closeHunks()
+		return m, nil
+	case key.Matches(msg, m.hunkKeys.Quit):
+		return m, tea.Quit
+	}
+
+	if m.mode == modeHunks {
+		m.viewport.SetContent(m.viewContent())
+		m.scrollHunks()
+	}
+	return m, nil
+}
+
+// scrollHunks keeps the cursor, and as much of the current hunk as fits, within
+// the viewport.
+func (m *model) scrollHunks() {
+	top := 1
+	for _, h := range m.hunks.diff.Hunks[:m.hunks.hunk] {
+		top += 1 + len(h.Lines)
+	}
+	bottom := top + len(m.hunks.diff.Hunks[m.hunks.hunk].Lines)
+	if m.hunks.lines {
+		top += 1 + m.hunks.line
+		bottom = top
+	} else if m.hunks.hunk == 0 {
+		top = 0
+	}
+
+	if bottom >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(bottom - m.viewport.Height + 1)
+	}
+	if top < m.viewport.YOffset {
+		m.viewport.SetYOffset(top)
+	}
+}
+
+func (m model) viewHunks() string {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+	truncate := gloss.NewStyle().MaxWidth(contentWidth - 2).Render
+
+	title := string(m.hunks.file.status) + " " + m.hunks.file.path
+	if gloss.Width(title) > contentWidth-16 {
+		title = "…" + title[max(0, gloss.Width(title)-contentWidth+16):]
+	}
+
+	var out strings.Builder
+	out.WriteString(m.getContentSeparator(title))
+
+	cursor := color.Cursor.Foreground("◈ ")
+	marker := color.Marker.Foreground("│ ")
+	for i, h := range m.hunks.diff.Hunks {
+		current := i == m.hunks.hunk
+
+		gutter := "  "
+		if current && !m.hunks.lines {
+			gutter = cursor
+		}
+		out.WriteString(gutter + color.HunkHeader.Foreground(truncate(h.Title())) + "\n")
+
+		for j, l := range h.Lines {
+			gutter := "  "
+			if current && m.hunks.lines && j == m.hunks.line {
+				gutter = cursor
+			} else if current {
+				gutter = marker
+			}
+			out.WriteString(gutter + diffLine(l, truncate) + "\n")
+		}
+	}
+
+	return out.String()
+}
+
+func diffLine(l git.Line, truncate func(...string) string) string {
+	text := truncate(string(l.Kind) + strings.ReplaceAll(l.Text, "\t", "    "))
+	switch l.Kind {
+	case git.Addition:
+		return color.DiffAdded.Foreground(text)
+	case git.Deletion:
+		return color.DiffDeleted.Foreground(text)
+	}
+	return text
+}
@@ -0,0 +1,265 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cv4x/got/config"
+)
+
+// The key bindings of each view are the fields of its key map, and can be set
+// by got.keys.<view>.<action>, where the action is the field name in lower
+// case. A binding added to a key map can be set like any other. The keys that
+// answer prompts are shared by all views, as got.keys.prompt.<action>.
+
+// keyMaps returns the key maps by the name of their view.
+func keyMaps() map[string]any {
+	return map[string]any{
+		"status":   &keys,
+		"hunks":    &hunkKeys,
+		"conflict": &conflictKeys,
+		"commit":   &commitKeys,
+		"review":   &reviewKeys,
+		"branch":   &branchKeys,
+		"log":      &logKeys,
+		"stash":    &stashKeys,
+		"rebase":   &rebaseKeys,
+		"prompt":   &promptKeys,
+	}
+}
+
+// helpPairs names the bindings that have no help of their own, by the binding
+// whose help shows them as well.
+var helpPairs = map[string]string{
+	"status.next": "prev",
+	"status.undo": "redo",
+	"log.next":    "previous",
+}
+
+// presets change the default bindings, which are vim's, by action. An action
+// applies to every view that has it, unless it is given as "<view>.<action>".
+var presets = map[string]map[string][]string{
+	"vim": {},
+	"emacs": {
+		"up":              {"ctrl+p", "up"},
+		"down":            {"ctrl+n", "down"},
+		"left":            {"ctrl+b", "left"},
+		"right":           {"ctrl+f", "right"},
+		"top":             {"alt+<", "home"},
+		"bottom":          {"alt+>", "end"},
+		"extendup":        {"shift+up"},
+		"extenddown":      {"shift+down"},
+		"moveup":          {"alt+p", "shift+up"},
+		"movedown":        {"alt+n", "shift+down"},
+		"scrollup":        {"alt+v", "pgup"},
+		"scrolldown":      {"ctrl+v", "pgdown"},
+		"filter":          {"ctrl+s", "/"},
+		"search":          {"ctrl+s", "/"},
+		"undo":            {"ctrl+_", "u"},
+		"conflict.ours":   {"o", "ctrl+b", "left"},
+		"conflict.theirs": {"t", "ctrl+f", "right"},
+	},
+	"arrows": {
+		"up":              {"up"},
+		"down":            {"down"},
+		"left":            {"left"},
+		"right":           {"right"},
+		"extendup":        {"shift+up"},
+		"extenddown":      {"shift+down"},
+		"moveup":          {"shift+up"},
+		"movedown":        {"shift+down"},
+		"scrollup":        {"pgup"},
+		"scrolldown":      {"pgdown"},
+		"conflict.ours":   {"o", "left"},
+		"conflict.theirs": {"t", "right"},
+	},
+}
+
+// keyAliases are names for keys that are awkward to write in a config file.
+var keyAliases = map[string]string{
+	"space": " ",
+	"comma": ",",
+}
+
+// configureKeys applies a preset and the bindings set in the configuration to
+// the key maps, and checks that no two actions of a view share a key.
+func configureKeys(c config.Config) error {
+	preset, ok := presets[c.Keymap]
+	if !ok {
+		return fmt.Errorf("got.keymap: there is no preset named %q", c.Keymap)
+	}
+	maps := keyMaps()
+	var errs []error
+	for _, name := range sortedKeys(c.Keys) {
+		b := c.Keys[name]
+		view, _, _ := strings.Cut(name, ".")
+		if _, ok := maps[view]; !ok {
+			errs = append(errs, fmt.Errorf("%s: there is no view named %q, only %s", b.Origin, view,
+				strings.Join(sortedKeys(maps), ", ")))
+		}
+	}
+	for _, view := range sortedKeys(maps) {
+		if err := rebind(view, maps[view], preset, c.Keys); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rebind sets the bindings of a key map from a preset and the configuration.
+func rebind(view string, km any, preset map[string][]string, custom map[string]config.Binding) error {
+	bindings := keyBindings(km)
+	changed := make(map[string]bool)
+	var errs []error
+
+	for name, keys := range preset {
+		if v, action, ok := strings.Cut(name, "."); ok {
+			if v != view {
+				continue
+			}
+			name = action
+		}
+		if b, ok := bindings[name]; ok {
+			b.SetKeys(keys...)
+			changed[name] = true
+		}
+	}
+	for _, name := range sortedKeys(custom) {
+		c := custom[name]
+		v, action, _ := strings.Cut(name, ".")
+		if v != view {
+			continue
+		}
+		b, ok := bindings[action]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s has no action named %q, only %s", c.Origin, view, action,
+				strings.Join(sortedKeys(bindings), ", ")))
+			continue
+		}
+		keys := make([]string, 0, len(c.Keys))
+		for _, k := range c.Keys {
+			if alias, ok := keyAliases[k]; ok {
+				k = alias
+			}
+			if !validKey(k) {
+				errs = append(errs, fmt.Errorf("%s: %q is not a key", c.Origin, k))
+			}
+			keys = append(keys, k)
+		}
+		if len(keys) == 0 {
+			b.Unbind()
+		} else {
+			b.SetKeys(keys...)
+		}
+		changed[action] = true
+	}
+
+	// report each conflict once, in a stable order
+	owner := make(map[string]string)
+	for _, action := range sortedKeys(bindings) {
+		for _, k := range bindings[action].Keys() {
+			if other, ok := owner[k]; ok {
+				errs = append(errs, fmt.Errorf("got.keys.%[1]s.%[2]s and got.keys.%[1]s.%[3]s are both bound to %[4]q",
+					view, other, action, keyName(k)))
+				continue
+			}
+			owner[k] = action
+		}
+	}
+
+	for action, b := range bindings {
+		pair := helpPairs[view+"."+action]
+		if b.Help().Key == "" || (!changed[action] && !changed[pair]) {
+			continue
+		}
+		var partner []string
+		if p, ok := bindings[pair]; ok {
+			partner = p.Keys()
+		}
+		b.SetHelp(helpKey(b.Keys(), partner), b.Help().Desc)
+	}
+	return errors.Join(errs...)
+}
+
+// keyBindings returns the bindings of a key map by action.
+func keyBindings(km any) map[string]*key.Binding {
+	bindings := make(map[string]*key.Binding)
+	v := reflect.ValueOf(km).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if b, ok := v.Field(i).Addr().Interface().(*key.Binding); ok {
+			bindings[strings.ToLower(v.Type().Field(i).Name)] = b
+		}
+	}
+	return bindings
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// keyNames holds the names bubbletea gives to keys other than characters.
+var keyNames = func() map[string]bool {
+	names := make(map[string]bool)
+	for t := tea.KeyType(-128); t < 128; t++ {
+		if s := t.String(); s != "" && t != tea.KeyRunes {
+			names[s] = true
+		}
+	}
+	return names
+}()
+
+func validKey(k string) bool {
+	k = strings.TrimPrefix(k, "alt+")
+	return utf8.RuneCountInString(k) == 1 || keyNames[k]
+}
+
+// helpKey shows the first key of a binding in the help, followed by the
+// second if the first is an arrow, or else by the first key of its partner.
+func helpKey(keys, partner []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	shown := []string{keyName(keys[0])}
+	switch {
+	case len(partner) > 0:
+		shown = append(shown, keyName(partner[0]))
+	case len(keys) > 1 && strings.Contains("↑↓←→", keyName(keys[0])):
+		shown = append(shown, keyName(keys[1]))
+	}
+	return strings.Join(shown, "/")
+}
+
+// keyName abbreviates a key the way the help does.
+func keyName(k string) string {
+	switch k {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case "enter":
+		return "ent"
+	case "pgdown":
+		return "pgdn"
+	case " ":
+		return "space"
+	}
+	if c, ok := strings.CutPrefix(k, "ctrl+"); ok {
+		return "^" + c
+	}
+	return k
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cv4x/got/config"
+)
+
+// copyKeyMap returns a copy of a key map, which can be rebound without
+// affecting the others tests.
+func copyKeyMap(km any) any {
+	v := reflect.New(reflect.TypeOf(km).Elem())
+	v.Elem().Set(reflect.ValueOf(km).Elem())
+	return v.Interface()
+}
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+
+	for name, preset := range presets {
+		for view, km := range keyMaps() {
+			if err := rebind(view, copyKeyMap(km), preset, nil); err != nil {
+				t.Errorf("preset %s: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+
+	km := copyKeyMap(&keys).(*keyMap)
+	err := rebind("status", km, presets["emacs"], map[string]config.Binding{
+		"status.next": {Keys: []string{"ctrl+n", "ctrl+j"}},
+		"status.mark": {Keys: []string{"space", "x"}},
+		"status.tree": {},
+	})
+	if err == nil || !strings.Contains(err.Error(), `got.keys.status.down and got.keys.status.next are both bound to "^n"`) {
+		t.Errorf("conflict not reported: %v", err)
+	}
+	if help := km.Up.Help(); help.Key != "^p" || help.Desc != keys.Up.Help().Desc {
+		t.Errorf("up help = %+v, want ^p", help)
+	}
+	if help := km.Next.Help().Key; help != "^n/N" {
+		t.Errorf("next help = %q, want it to show prev as well", help)
+	}
+	if !km.Mark.Enabled() || km.Mark.Help().Key != "space" {
+		t.Errorf("mark is not bound to space")
+	}
+	if km.Tree.Enabled() {
+		t.Errorf("tree is still bound")
+	}
+	// keys that were not rebound keep their help
+	if help := km.Submit.Help().Key; help != "ent/y" {
+		t.Errorf("submit help = %q, want ent/y", help)
+	}
+
+	err = rebind("status", copyKeyMap(&keys), nil, map[string]config.Binding{
+		"status.stage": {Keys: []string{"s"}, Origin: "here"},
+		"status.up":    {Keys: []string{"ctrl+ff"}, Origin: "there"},
+	})
+	for _, want := range []string{`here: status has no action named "stage"`, `there: "ctrl+ff" is not a key`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q: %v", want, err)
+		}
+	}
+}
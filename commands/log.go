@@ -0,0 +1,615 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+	"github.com/muesli/termenv"
+)
+
+// Lines of the diffstat in the detail pane are at most this wide, bar
+// included.
+const diffstatWidth = 60
+
+type logKeyMap struct {
+	Up         key.Binding
+	Down       key.Binding
+	Top        key.Binding
+	Bottom     key.Binding
+	Search     key.Binding
+	Next       key.Binding
+	Previous   key.Binding
+	Copy       key.Binding
+	Checkout   key.Binding
+	Branch     key.Binding
+	CherryPick key.Binding
+	Revert     key.Binding
+	ScrollUp   key.Binding
+	ScrollDown key.Binding
+	Quit       key.Binding
+}
+
+func (k logKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Quit}
+}
+
+func (k logKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Search, k.Next},
+		{k.Copy, k.Checkout},
+		{k.Branch, k.CherryPick},
+		{k.Revert, k.Quit},
+	}
+}
+
+var logKeys = logKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "down   "),
+	),
+	Top: key.NewBinding(
+		key.WithKeys("home"),
+		key.WithHelp("home", "top   "),
+	),
+	Bottom: key.NewBinding(
+		key.WithKeys("end"),
+		key.WithHelp("end", "bottom   "),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "search   "),
+	),
+	Next: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n/N", "next   "),
+	),
+	Previous: key.NewBinding(
+		key.WithKeys("N"),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy   "),
+	),
+	Checkout: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "checkout   "),
+	),
+	Branch: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "branch   "),
+	),
+	CherryPick: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pick   "),
+	),
+	Revert: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "revert   "),
+	),
+	ScrollUp: key.NewBinding(
+		key.WithKeys("pgup", "ctrl+u"),
+		key.WithHelp("pgup", "scroll detail up   "),
+	),
+	ScrollDown: key.NewBinding(
+		key.WithKeys("pgdown", "ctrl+d"),
+		key.WithHelp("pgdn", "scroll detail down   "),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	),
+}
+
+// logModel shows the commit graph with the message and diffstat of the
+// selected commit next to or below it. The cursor only stops on commits, not
+// on the lines of the graph between them.
+type logModel struct {
+	frame
+	term      dimensions
+	keys      logKeyMap
+	head      head
+	options   git.LogOptions
+	entries   []git.LogEntry
+	selected  int
+	detail    viewport.Model
+	loaded    string
+	search    textinput.Model
+	searching bool
+	message   string
+}
+
+func Log(state git.RepoState, args []string) error {
+	flagset := flag.NewFlagSet("got log", flag.ExitOnError)
+	all := flagset.Bool("all", false, "show the commits of all refs, not only of HEAD")
+	limit := flagset.Int("n", 1000, "show at most this many commits, or all if 0")
+	if err := flagset.Parse(args); err != nil {
+		flagset.Usage()
+	}
+
+	options := git.LogOptions{All: *all, Max: *limit}
+	entries, err := git.Log(options)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("no commits yet")
+		return nil
+	}
+
+	search := textinput.New()
+	search.Prompt = "/"
+	model := logModel{
+		frame:   newFrame(),
+		keys:    logKeys,
+		head:    newHead(state),
+		options: options,
+		entries: entries,
+		search:  search,
+	}
+	model.selected = model.nextCommit(-1, 1)
+
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		log.Fatalf("Fatal error: %v", err)
+	}
+	return nil
+}
+
+func (m logModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m logModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.message = ""
+		switch {
+		case m.prompt != promptNone:
+			return m.updatePrompt(msg)
+		case m.searching:
+			return m.updateSearch(msg)
+		}
+
+		current := m.entries[m.selected]
+		switch {
+		case key.Matches(msg, m.keys.Up):
+			m.selected = m.nextCommit(m.selected, -1)
+		case key.Matches(msg, m.keys.Down):
+			m.selected = m.nextCommit(m.selected, 1)
+		case key.Matches(msg, m.keys.Top):
+			m.selected = m.nextCommit(-1, 1)
+		case key.Matches(msg, m.keys.Bottom):
+			m.selected = m.nextCommit(len(m.entries), -1)
+		case key.Matches(msg, m.keys.Search):
+			m.searching = true
+			m.search.SetValue("")
+			cmd = m.search.Focus()
+		case key.Matches(msg, m.keys.Next):
+			m.find(1)
+		case key.Matches(msg, m.keys.Previous):
+			m.find(-1)
+		case key.Matches(msg, m.keys.Copy):
+			copyText(current.OID)
+		case key.Matches(msg, m.keys.Checkout):
+			if err := git.CheckoutDetached(current.OID); err != nil {
+				m.fail(err)
+				break
+			}
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Branch):
+			cmd = m.ask(promptCreate, "")
+		case key.Matches(msg, m.keys.CherryPick):
+			m.prompt = promptCherryPick
+		case key.Matches(msg, m.keys.Revert):
+			m.prompt = promptRevert
+		case key.Matches(msg, m.keys.ScrollUp):
+			m.detail.HalfViewUp()
+		case key.Matches(msg, m.keys.ScrollDown):
+			m.detail.HalfViewDown()
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.term.width = msg.Width
+		m.term.height = msg.Height
+		m.resize()
+
+	default:
+		// keep the cursor of the prompt or the search blinking
+		switch {
+		case m.prompt != promptNone:
+			m.input, cmd = m.input.Update(msg)
+		case m.searching:
+			m.search, cmd = m.search.Update(msg)
+		}
+	}
+
+	m.syncDetail()
+	return m, cmd
+}
+
+// nextCommit returns the index of the next commit from i in the direction of
+// delta, or i if there is none.
+func (m logModel) nextCommit(i, delta int) int {
+	for j := i + delta; j >= 0 && j < len(m.entries); j += delta {
+		if m.entries[j].OID != "" {
+			return j
+		}
+	}
+	return max(0, i)
+}
+
+func (m logModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.promptKeys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, m.promptKeys.Cancel):
+		m.searching = false
+		m.search.Blur()
+		m.search.Reset()
+		return m, nil
+	case key.Matches(msg, m.promptKeys.Submit):
+		m.searching = false
+		m.search.Blur()
+		m.find(1)
+		m.syncDetail()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.search, cmd = m.search.Update(msg)
+	return m, cmd
+}
+
+// find moves the cursor to the next commit in the direction of delta that
+// matches the search, wrapping around at either end of the log.
+func (m *logModel) find(delta int) {
+	query := strings.ToLower(m.search.Value())
+	if query == "" {
+		return
+	}
+
+	n := len(m.entries)
+	for step := 1; step <= n; step++ {
+		i := (m.selected + step*delta + n*n) % n
+		if matches(m.entries[i], query) {
+			m.selected = i
+			return
+		}
+	}
+	m.message = "no commit matches " + m.search.Value()
+}
+
+// matches reports whether the subject, author, hash or refs of a commit contain
+// query, which must be lower case.
+func matches(e git.LogEntry, query string) bool {
+	if e.OID == "" {
+		return false
+	}
+	fields := []string{e.Subject, e.Author, e.OID}
+	for _, r := range e.Refs {
+		fields = append(fields, r.Name)
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m logModel) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	current := m.entries[m.selected]
+
+	p, name, cmd := m.answer(msg)
+	switch p {
+	case promptCherryPick:
+		m.reload(git.CherryPick(current.OID))
+	case promptRevert:
+		m.reload(git.Revert(current.OID))
+	case promptCreate:
+		m.reload(git.CreateBranch(name, current.OID))
+	}
+
+	m.syncDetail()
+	return m, cmd
+}
+
+// reload reads the log again after an action that added commits or refs,
+// keeping the cursor on the same commit if it is still listed, and shows the
+// error of the action if there is one.
+func (m *logModel) reload(err error) {
+	if err != nil {
+		m.fail(err)
+	}
+
+	oid := m.entries[m.selected].OID
+	entries, err := git.Log(m.options)
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	if state, err := git.CurrentRef(); err == nil {
+		m.head = newHead(state)
+	}
+
+	m.entries = entries
+	m.selected = m.nextCommit(-1, 1)
+	for i, e := range entries {
+		if e.OID == oid {
+			m.selected = i
+		}
+	}
+	m.loaded = ""
+}
+
+func (m *logModel) fail(err error) {
+	m.message = err.Error()
+}
+
+func (m *logModel) resize() {
+	if m.term.width == 0 {
+		return
+	}
+
+	list, pane := split(m.term, paneLayout(m.term))
+	fitPane(&m.detail, pane)
+	m.loaded = ""
+	m.fit(list, gloss.Height(m.viewHeader()), gloss.Height(m.viewFooter()), 30)
+	m.search.Width = max(1, m.viewport.Width/2)
+}
+
+// syncDetail loads the message and diffstat of the selected commit if they are
+// not the ones already shown.
+func (m *logModel) syncDetail() {
+	if paneLayout(m.term) == previewHidden {
+		return
+	}
+	current := m.entries[m.selected]
+	if current.OID == m.loaded {
+		return
+	}
+
+	message, stats, err := git.CommitDetail(current.OID)
+	if err != nil {
+		m.fail(err)
+	}
+	m.loaded = current.OID
+
+	truncate := gloss.NewStyle().MaxWidth(m.detail.Width).Render
+	var out strings.Builder
+	out.WriteString(color.Ref.Foreground(truncate("commit "+current.OID)) + "\n")
+	if refs := decorations(current.Refs); refs != "" {
+		out.WriteString(truncate(refs) + "\n")
+	}
+	out.WriteString(truncate("Author: "+current.Author) + "\n")
+	out.WriteString(truncate("Date:   "+current.Date.Format("Mon Jan 2 15:04:05 2006 -0700")+" ("+relativeTime(current.Date)+")") + "\n\n")
+	for _, l := range strings.Split(message, "\n") {
+		out.WriteString(truncate("    "+l) + "\n")
+	}
+	if len(stats) > 0 {
+		out.WriteString("\n" + diffstat(stats, min(m.detail.Width, diffstatWidth)))
+	}
+
+	m.detail.SetContent(out.String())
+	m.detail.GotoTop()
+}
+
+func (m logModel) View() string {
+	if s, ok := m.tooSmall(m.keys.Quit); ok {
+		return s
+	}
+
+	m.viewport.SetContent(m.viewContent())
+	m.keepInView(m.selected)
+
+	list := renderFrame(m.xy.height, m.viewHeader(), m.viewport, m.viewFooter())
+	switch paneLayout(m.term) {
+	case previewRight:
+		return gloss.JoinHorizontal(gloss.Top, list, m.viewDetail())
+	case previewBelow:
+		return gloss.JoinVertical(gloss.Left, list, m.viewDetail())
+	}
+	return list
+}
+
+func (m logModel) viewHeader() string {
+	var subtitle string
+	switch {
+	case m.searching:
+		subtitle = m.search.View()
+	case m.search.Value() != "":
+		subtitle = "/" + m.search.Value()
+	}
+	return renderHeader(m.viewport.Width, m.head.title(), subtitle)
+}
+
+func (m logModel) viewFooter() string {
+	return renderFooter(m.viewport.Width, m.help, m.keys, m.message)
+}
+
+func (m logModel) viewContent() string {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+	truncate := gloss.NewStyle().MaxWidth(contentWidth).Render
+	query := strings.ToLower(m.search.Value())
+
+	var out strings.Builder
+	for i, e := range m.entries {
+		cursor := "   "
+		if i == m.selected {
+			cursor = color.Cursor.Foreground(" ◈ ")
+		}
+		line := cursor + e.Graph
+		if e.OID != "" {
+			subject := e.Subject
+			if query != "" {
+				// lower casing may change the length of some characters
+				if start := strings.Index(strings.ToLower(subject), query); start >= 0 && start+len(query) <= len(subject) {
+					subject = subject[:start] + color.Search.Foreground(subject[start:start+len(query)]) + subject[start+len(query):]
+				}
+			}
+			line += " " + color.Ref.Foreground(e.OID[:7])
+			if refs := decorations(e.Refs); refs != "" {
+				line += " " + refs
+			}
+			line += " " + subject
+		}
+		out.WriteString(truncate(line) + "\n")
+	}
+
+	if m.prompt != promptNone {
+		short := m.entries[m.selected].OID[:7]
+		out.WriteString("\n")
+		switch m.prompt {
+		case promptCreate:
+			out.WriteString("New branch at " + short + ": " + m.input.View())
+		case promptCherryPick:
+			out.WriteString(color.Warning.Foreground("Cherry-pick "+short+" onto "+m.head.name+"?") + " " + m.promptKeys.choices())
+		case promptRevert:
+			out.WriteString(color.Warning.Foreground("Revert "+short+" on "+m.head.name+"?") + " " + m.promptKeys.choices())
+		}
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+func (m logModel) viewDetail() string {
+	title := color.Note.Foreground(fmt.Sprintf("%d/%d", m.position(), m.commits()))
+	return previewStyle.Render(title + "\n" + m.detail.View())
+}
+
+// position returns the number of the selected commit, counting from one.
+func (m logModel) position() int {
+	n := 0
+	for _, e := range m.entries[:m.selected+1] {
+		if e.OID != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (m logModel) commits() int {
+	n := 0
+	for _, e := range m.entries {
+		if e.OID != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// decorations renders refs in the colors of the theme, which are git's by
+// default: HEAD in cyan, local branches in green, remote-tracking branches in
+// red and tags in yellow.
+func decorations(refs []git.Ref) string {
+	if len(refs) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(refs))
+	for i := 0; i < len(refs); i++ {
+		r := refs[i]
+		switch r.Kind {
+		case git.RefHead:
+			// HEAD is followed by the branch it points to, if any
+			if i+1 < len(refs) && refs[i+1].Kind == git.RefBranch {
+				parts = append(parts, color.RefHead.Foreground("HEAD -> ")+color.RefBranch.Foreground(refs[i+1].Name))
+				i++
+				continue
+			}
+			parts = append(parts, color.RefHead.Foreground(r.Name))
+		case git.RefBranch:
+			parts = append(parts, color.RefBranch.Foreground(r.Name))
+		case git.RefRemote:
+			parts = append(parts, color.RefRemote.Foreground(r.Name))
+		case git.RefTag:
+			parts = append(parts, color.RefTag.Foreground("tag: "+r.Name))
+		}
+	}
+	return color.Ref.Foreground("(") + strings.Join(parts, color.Ref.Foreground(", ")) + color.Ref.Foreground(")")
+}
+
+// diffstat renders the lines changed per file like "git diff --stat", within
+// width.
+func diffstat(stats []git.FileStat, width int) string {
+	var (
+		out      strings.Builder
+		most     int
+		files    int
+		added    int
+		deleted  int
+		numWidth int
+	)
+	for _, s := range stats {
+		most = max(most, s.Additions+s.Deletions)
+		numWidth = max(numWidth, len(fmt.Sprint(s.Additions+s.Deletions)))
+	}
+
+	pathWidth := max(10, width/2)
+	barWidth := max(1, width-pathWidth-numWidth-4)
+	for _, s := range stats {
+		files++
+		path := s.Path
+		if gloss.Width(path) > pathWidth {
+			path = "…" + path[len(path)-pathWidth+1:]
+		}
+		out.WriteString(" " + path + strings.Repeat(" ", max(0, pathWidth-gloss.Width(path))) + " | ")
+
+		if s.Additions < 0 {
+			out.WriteString("Bin\n")
+			continue
+		}
+		added += s.Additions
+		deleted += s.Deletions
+
+		plus, minus := s.Additions, s.Deletions
+		if most > barWidth {
+			// scale the bar, but keep at least one mark for any change
+			plus = (s.Additions*barWidth + most - 1) / most
+			minus = (s.Deletions*barWidth + most - 1) / most
+		}
+		out.WriteString(fmt.Sprintf("%*d ", numWidth, s.Additions+s.Deletions))
+		out.WriteString(color.DiffAdded.Foreground(strings.Repeat("+", plus)))
+		out.WriteString(color.DiffDeleted.Foreground(strings.Repeat("-", minus)) + "\n")
+	}
+
+	out.WriteString(fmt.Sprintf(" %d %s changed, %d %s(+), %d %s(-)\n",
+		files, plural(files, "file", "files"),
+		added, plural(added, "insertion", "insertions"),
+		deleted, plural(deleted, "deletion", "deletions")))
+	return out.String()
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// copyText puts s on the system clipboard. Where there is no clipboard
+// utility, e.g. over ssh, it asks the terminal to do so instead.
+func copyText(s string) {
+	if err := clipboard.WriteAll(s); err != nil {
+		termenv.Copy(s)
+	}
+}
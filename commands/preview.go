@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+var previewStyle = gloss.NewStyle().Border(gloss.RoundedBorder()).Padding(0, 1)
+
+// Terminals at least this wide show the preview next to the file list instead
+// of below it.
+const sideBySideWidth = 120
+
+// Terminals shorter than this have no room to stack the preview below the file
+// list, so it stays hidden until the terminal grows.
+const stackedMinHeight = 24
+
+// Untracked files larger than this are not previewed.
+const previewMaxSize = 1 << 20
+
+type layout byte
+
+const (
+	previewHidden layout = iota
+	previewRight
+	previewBelow
+)
+
+// previewPane shows the diff of the file under the cursor in its own
+// viewport, which scrolls independently of the file list.
+type previewPane struct {
+	show     bool
+	viewport viewport.Model
+	target   file
+	diff     git.FileDiff
+	// chunks hold the content of a conflicted file, which git shows as a
+	// combined diff that the preview does not parse.
+	chunks []git.Chunk
+	// note explains why there is nothing to show.
+	note   string
+	loaded bool
+}
+
+func (m model) layout() layout {
+	if !m.preview.show || m.mode != modeFiles || m.clean {
+		return previewHidden
+	}
+	return paneLayout(m.term)
+}
+
+// paneLayout places a pane next to a list if the terminal is wide enough, or
+// below it if it is tall enough.
+func paneLayout(term dimensions) layout {
+	switch {
+	case term.width >= sideBySideWidth:
+		return previewRight
+	case term.height >= stackedMinHeight:
+		return previewBelow
+	}
+	return previewHidden
+}
+
+// split divides the terminal between a list and a pane placed by l.
+func split(term dimensions, l layout) (list, pane dimensions) {
+	switch l {
+	case previewRight:
+		list = dimensions{max(40, capWidth(term.width/2)), term.height}
+		return list, dimensions{term.width - list.width, term.height}
+	case previewBelow:
+		list = dimensions{term.width, term.height / 2}
+		return list, dimensions{term.width, term.height - list.height}
+	}
+	return dimensions{capWidth(term.width), term.height}, dimensions{}
+}
+
+// fitPane sizes the viewport of a pane so that the pane, drawn with
+// previewStyle, fills d.
+func fitPane(vp *viewport.Model, d dimensions) {
+	// leave room for the border, padding and title line
+	vp.Width = max(0, d.width-previewStyle.GetHorizontalFrameSize())
+	vp.Height = max(0, d.height-previewStyle.GetVerticalFrameSize()-1)
+}
+
+func (p *previewPane) resize(d dimensions) {
+	fitPane(&p.viewport, d)
+	p.render()
+}
+
+// syncPreview loads the diff of the file under the cursor if it is not the one
+// already shown.
+func (m *model) syncPreview() {
+	// keep the last file while none passes the filter
+	if m.layout() == previewHidden || !m.visible(m.selected) {
+		return
+	}
+
+	f := m.files[m.selected]
+	if m.preview.loaded && f.category == m.preview.target.category && f.path == m.preview.target.path {
+		return
+	}
+
+	path := m.rootdir + "/" + f.path
+	m.preview.diff, m.preview.chunks, m.preview.note = git.FileDiff{}, nil, ""
+	switch f.category {
+	case Conflicts:
+		m.preview.chunks, m.preview.note = conflictPreview(path, f.conflict)
+	case Untracked:
+		m.preview.diff = untrackedDiff(path)
+	default:
+		diff, err := m.repo.Diff(path, f.staged)
+		if err != nil {
+			m.fail(err)
+		}
+		m.preview.diff = diff
+	}
+	m.preview.target = f
+	m.preview.loaded = true
+	m.preview.render()
+	m.preview.viewport.GotoTop()
+}
+
+func (p *previewPane) render() {
+	var out strings.Builder
+	switch {
+	case p.note != "":
+		out.WriteString(color.Note.Foreground(p.note) + "\n")
+	case p.chunks != nil:
+		writeChunks(&out, p.chunks, p.viewport.Width)
+	default:
+		writeDiff(&out, p.diff, p.viewport.Width)
+	}
+	p.viewport.SetContent(out.String())
+}
+
+// writeDiff renders the hunks of a diff, truncating lines to the given width.
+func writeDiff(out *strings.Builder, d git.FileDiff, width int) {
+	truncate := gloss.NewStyle().MaxWidth(width).Render
+
+	if d.Binary {
+		out.WriteString(color.Note.Foreground("Binary file") + "\n")
+	}
+	for _, h := range d.Hunks {
+		out.WriteString(color.HunkHeader.Foreground(truncate(h.Title())) + "\n")
+		for _, l := range h.Lines {
+			out.WriteString(diffLine(l, truncate) + "\n")
+		}
+	}
+}
+
+// writeChunks renders a conflicted file with its conflict markers, truncating
+// lines to the given width.
+func writeChunks(out *strings.Builder, chunks []git.Chunk, width int) {
+	truncate := gloss.NewStyle().MaxWidth(width).Render
+	text := func(l string) string {
+		return truncate(strings.ReplaceAll(strings.TrimRight(l, "\r\n"), "\t", "    "))
+	}
+	marker := func(m, label string) {
+		out.WriteString(color.ConflictLabel.Foreground(truncate(strings.TrimSpace(m+" "+label))) + "\n")
+	}
+	section := func(lines []string, style func(...string) string) {
+		for _, l := range lines {
+			if style != nil {
+				out.WriteString(style(text(l)) + "\n")
+			} else {
+				out.WriteString(text(l) + "\n")
+			}
+		}
+	}
+	for _, c := range chunks {
+		if !c.Conflict {
+			section(c.Lines, nil)
+			continue
+		}
+		marker("<<<<<<<", sideLabel(c.OursLabel, "ours"))
+		section(c.Ours, color.ConflictOurs.Foreground)
+		if c.BaseLabel != "" || len(c.Base) > 0 {
+			marker("|||||||", sideLabel(c.BaseLabel, "base"))
+			section(c.Base, color.ConflictBase.Foreground)
+		}
+		marker("=======", "")
+		section(c.Theirs, color.ConflictTheirs.Foreground)
+		marker(">>>>>>>", sideLabel(c.TheirsLabel, "theirs"))
+	}
+}
+
+// conflictPreview reads a conflicted file for the preview, or explains why
+// there is nothing to show, as when one side deleted the file.
+func conflictPreview(path string, c git.Conflict) ([]git.Chunk, string) {
+	chunks, err := readConflict(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, c.String() + ", the file is not in the worktree"
+		}
+		return nil, err.Error()
+	}
+	for _, ch := range chunks {
+		if ch.Conflict {
+			return chunks, ""
+		}
+	}
+	return nil, c.String() + ", there are no conflict markers in the file"
+}
+
+func (m model) viewPreview() string {
+	title := string(m.preview.target.status) + " " + m.preview.target.path
+	if gloss.Width(title) > m.preview.viewport.Width {
+		title = "…" + title[max(0, gloss.Width(title)-m.preview.viewport.Width+1):]
+	}
+	title = color.Note.Foreground(title)
+
+	return previewStyle.Render(title + "\n" + m.preview.viewport.View())
+}
+
+// untrackedDiff presents the content of an untracked file as a diff that adds
+// every line.
+func untrackedDiff(path string) git.FileDiff {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > previewMaxSize {
+		return git.FileDiff{}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return git.FileDiff{}
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return git.FileDiff{Binary: true}
+	}
+
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return git.FileDiff{}
+	}
+
+	var hunk git.Hunk
+	for _, l := range strings.Split(text, "\n") {
+		hunk.Lines = append(hunk.Lines, git.Line{Kind: git.Addition, Text: l})
+	}
+	hunk.NewStart = 1
+	hunk.NewLines = len(hunk.Lines)
+	return git.FileDiff{Hunks: []git.Hunk{hunk}}
+}
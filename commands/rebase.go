@@ -0,0 +1,486 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+type rebaseKeyMap struct {
+	Up         key.Binding
+	Down       key.Binding
+	MoveUp     key.Binding
+	MoveDown   key.Binding
+	Pick       key.Binding
+	Reword     key.Binding
+	Edit       key.Binding
+	Squash     key.Binding
+	Fixup      key.Binding
+	Drop       key.Binding
+	Exec       key.Binding
+	ScrollUp   key.Binding
+	ScrollDown key.Binding
+	Submit     key.Binding
+	Quit       key.Binding
+}
+
+func (k rebaseKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Quit}
+}
+
+func (k rebaseKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.MoveUp, k.MoveDown},
+		{k.Pick, k.Reword},
+		{k.Edit, k.Squash},
+		{k.Fixup, k.Drop},
+		{k.Exec, k.Submit},
+		{k.Quit},
+	}
+}
+
+var rebaseKeys = rebaseKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "down   "),
+	),
+	MoveUp: key.NewBinding(
+		key.WithKeys("shift+up", "K"),
+		key.WithHelp("K", "move up   "),
+	),
+	MoveDown: key.NewBinding(
+		key.WithKeys("shift+down", "J"),
+		key.WithHelp("J", "move down   "),
+	),
+	Pick: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pick   "),
+	),
+	Reword: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "reword   "),
+	),
+	Edit: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit   "),
+	),
+	Squash: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "squash   "),
+	),
+	Fixup: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "fixup   "),
+	),
+	Drop: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "drop   "),
+	),
+	Exec: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "exec   "),
+	),
+	ScrollUp: key.NewBinding(
+		key.WithKeys("pgup", "ctrl+u"),
+		key.WithHelp("pgup", "scroll preview up   "),
+	),
+	ScrollDown: key.NewBinding(
+		key.WithKeys("pgdown", "ctrl+d"),
+		key.WithHelp("pgdn", "scroll preview down   "),
+	),
+	Submit: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("ent", "rebase   "),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "abort"),
+	),
+}
+
+// rebaseModel edits the todo list of an interactive rebase, with the diff of
+// the selected commit next to or below the list. The list is in the order git
+// applies it, oldest commit first.
+type rebaseModel struct {
+	frame
+	term     dimensions
+	keys     rebaseKeyMap
+	head     head
+	todo     []git.TodoLine
+	selected int
+	// changed is set once the list was edited, so that quitting asks first
+	changed bool
+	// done is set when the list is to be handed back to git
+	done    bool
+	preview viewport.Model
+	// loaded is the object ID of the commit shown in the preview
+	loaded  string
+	message string
+}
+
+// Rebase starts an interactive rebase with got as the sequence editor, or, with
+// -todo, is that editor: git hands it the todo list to edit, which is how got
+// also serves plain "git rebase -i" when set as sequence.editor.
+func Rebase(state git.RepoState, args []string) error {
+	flagset := flag.NewFlagSet("got rebase", flag.ExitOnError)
+	interactive := flagset.Bool("i", false, "edit the list of commits before rebasing")
+	todo := flagset.String("todo", "", "edit the rebase todo list in `file`, as the sequence editor of git")
+	if err := flagset.Parse(args); err != nil {
+		flagset.Usage()
+	}
+
+	if *todo != "" {
+		return editTodo(state, *todo)
+	}
+	if !*interactive || flagset.NArg() > 1 {
+		return errors.New("usage: got rebase -i [<base>]")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	editor := shellQuote(self) + " rebase --todo"
+	return git.RebaseInteractive(flagset.Arg(0), editor)
+}
+
+// shellQuote quotes s for a POSIX shell, which is how git runs editors.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// editTodo lets the user edit the todo list in path, and writes it back for git
+// to carry out. Aborting leaves the file empty, which makes git stop the
+// rebase.
+func editTodo(state git.RepoState, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	model := newRebaseModel(state, git.ParseTodo(string(data)))
+
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
+		log.Fatalf("Fatal error: %v", err)
+	}
+
+	var out string
+	if m := final.(rebaseModel); m.done {
+		out = git.FormatTodo(m.todo)
+	}
+	return os.WriteFile(path, []byte(out), 0o644)
+}
+
+func newRebaseModel(state git.RepoState, todo []git.TodoLine) rebaseModel {
+	return rebaseModel{
+		frame: newFrame(),
+		keys:  rebaseKeys,
+		head:  newHead(state),
+		todo:  todo,
+	}
+}
+
+func (m rebaseModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m rebaseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.message = ""
+		if m.prompt != promptNone {
+			return m.updatePrompt(msg)
+		}
+		if len(m.todo) == 0 {
+			if key.Matches(msg, m.keys.Quit) {
+				return m, tea.Quit
+			}
+			break
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Up):
+			m.selected = (m.selected + len(m.todo) - 1) % len(m.todo)
+		case key.Matches(msg, m.keys.Down):
+			m.selected = (m.selected + 1) % len(m.todo)
+		case key.Matches(msg, m.keys.MoveUp):
+			m.move(-1)
+		case key.Matches(msg, m.keys.MoveDown):
+			m.move(1)
+		case key.Matches(msg, m.keys.Pick):
+			m.mark(git.Pick)
+		case key.Matches(msg, m.keys.Reword):
+			m.mark(git.Reword)
+		case key.Matches(msg, m.keys.Edit):
+			m.mark(git.Edit)
+		case key.Matches(msg, m.keys.Squash):
+			m.mark(git.Squash)
+		case key.Matches(msg, m.keys.Fixup):
+			m.mark(git.Fixup)
+		case key.Matches(msg, m.keys.Drop):
+			if m.todo[m.selected].OID == "" {
+				m.remove()
+			} else {
+				m.mark(git.Drop)
+			}
+		case key.Matches(msg, m.keys.Exec):
+			cmd = m.ask(promptExec, "")
+		case key.Matches(msg, m.keys.ScrollUp):
+			m.preview.HalfViewUp()
+		case key.Matches(msg, m.keys.ScrollDown):
+			m.preview.HalfViewDown()
+		case key.Matches(msg, m.keys.Submit):
+			if err := checkTodo(m.todo); err != nil {
+				m.fail(err)
+				break
+			}
+			m.done = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Quit):
+			if m.changed && !key.Matches(msg, m.promptKeys.Quit) {
+				m.prompt = promptAbort
+				break
+			}
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.term.width = msg.Width
+		m.term.height = msg.Height
+		m.resize()
+
+	default:
+		// keep the cursor of the prompt blinking
+		if m.prompt != promptNone {
+			m.input, cmd = m.input.Update(msg)
+		}
+	}
+
+	m.syncPreview()
+	return m, cmd
+}
+
+// mark sets the command of the selected commit and moves on to the next line,
+// so that a run of commits can be marked in a row.
+func (m *rebaseModel) mark(command string) {
+	l := &m.todo[m.selected]
+	if l.OID == "" {
+		return
+	}
+	if l.Command != command {
+		l.Command = command
+		m.changed = true
+	}
+	m.selected = min(m.selected+1, len(m.todo)-1)
+}
+
+// move swaps the selected line with the one above or below it, keeping the
+// cursor on the line.
+func (m *rebaseModel) move(delta int) {
+	i, j := m.selected, m.selected+delta
+	if j < 0 || j >= len(m.todo) {
+		return
+	}
+	m.todo[i], m.todo[j] = m.todo[j], m.todo[i]
+	m.selected = j
+	m.changed = true
+}
+
+// remove deletes the selected line, for lines that are not commits and so
+// cannot be dropped.
+func (m *rebaseModel) remove() {
+	m.todo = append(m.todo[:m.selected], m.todo[m.selected+1:]...)
+	m.selected = min(m.selected, max(0, len(m.todo)-1))
+	m.changed = true
+}
+
+// checkTodo reports what git would refuse to carry out in a todo list.
+func checkTodo(todo []git.TodoLine) error {
+	for _, l := range todo {
+		switch l.Command {
+		case git.Drop:
+			continue
+		case git.Squash, git.Fixup:
+			return fmt.Errorf("cannot %s %s without a commit before it", l.Command, l.OID)
+		}
+		if l.OID != "" {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m rebaseModel) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p, command, cmd := m.answer(msg)
+	switch p {
+	case promptAbort:
+		return m, tea.Quit
+	case promptExec:
+		// the command runs after the selected line
+		at := min(m.selected+1, len(m.todo))
+		line := git.TodoLine{Command: git.Exec, Text: command}
+		m.todo = append(m.todo[:at], append([]git.TodoLine{line}, m.todo[at:]...)...)
+		m.selected = at
+		m.changed = true
+	}
+	return m, cmd
+}
+
+func (m *rebaseModel) fail(err error) {
+	m.message = err.Error()
+}
+
+func (m *rebaseModel) resize() {
+	if m.term.width == 0 {
+		return
+	}
+
+	list, pane := split(m.term, m.layout())
+	fitPane(&m.preview, pane)
+	m.loaded = ""
+	m.fit(list, gloss.Height(m.viewHeader()), gloss.Height(m.viewFooter()), 20)
+}
+
+func (m rebaseModel) layout() layout {
+	return paneLayout(m.term)
+}
+
+// syncPreview loads the diff of the selected commit if it is not the one
+// already shown. Lines that are not commits show nothing.
+func (m *rebaseModel) syncPreview() {
+	if m.layout() == previewHidden || len(m.todo) == 0 {
+		return
+	}
+	current := m.todo[m.selected]
+	if current.OID == m.loaded {
+		return
+	}
+	m.loaded = current.OID
+
+	var out strings.Builder
+	if current.OID != "" {
+		diffs, err := git.CommitDiff(current.OID)
+		if err != nil {
+			m.fail(err)
+		}
+		for _, d := range diffs {
+			out.WriteString(color.DiffFile.Foreground(gloss.NewStyle().MaxWidth(m.preview.Width).Render(d.Path())) + "\n")
+			writeDiff(&out, d, m.preview.Width)
+		}
+	}
+	m.preview.SetContent(out.String())
+	m.preview.GotoTop()
+}
+
+func (m rebaseModel) View() string {
+	if s, ok := m.tooSmall(m.keys.Quit); ok {
+		return s
+	}
+
+	m.viewport.SetContent(m.viewContent())
+	m.keepInView(m.selected)
+
+	list := renderFrame(m.xy.height, m.viewHeader(), m.viewport, m.viewFooter())
+	switch m.layout() {
+	case previewRight:
+		return gloss.JoinHorizontal(gloss.Top, list, m.viewPreview())
+	case previewBelow:
+		return gloss.JoinVertical(gloss.Left, list, m.viewPreview())
+	}
+	return list
+}
+
+func (m rebaseModel) viewHeader() string {
+	var commits int
+	for _, l := range m.todo {
+		if l.OID != "" && l.Command != git.Drop {
+			commits++
+		}
+	}
+	return renderHeader(m.viewport.Width, m.head.title(), fmt.Sprintf("%d %s", commits, plural(commits, "commit", "commits")))
+}
+
+func (m rebaseModel) viewFooter() string {
+	return renderFooter(m.viewport.Width, m.help, m.keys, m.message)
+}
+
+func (m rebaseModel) viewContent() string {
+	if len(m.todo) == 0 {
+		return "\nnothing to rebase\n"
+	}
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+
+	var out strings.Builder
+	for i, l := range m.todo {
+		cursor := "   "
+		if i == m.selected {
+			cursor = color.Cursor.Foreground(" ◈ ")
+		}
+		style := todoStyle(l.Command)
+		line := style(fmt.Sprintf("%-6s", l.Command)) + " "
+		if l.OID != "" {
+			line += color.Ref.Foreground(l.OID) + " "
+		}
+		text := l.Text
+		room := contentWidth - gloss.Width(cursor) - gloss.Width(line)
+		if gloss.Width(text) > room {
+			text = gloss.NewStyle().MaxWidth(max(0, room-1)).Render(text) + "…"
+		}
+		if l.Command == git.Drop {
+			text = color.TodoDrop.Foreground(text)
+		}
+		out.WriteString(cursor + line + text + "\n")
+	}
+
+	switch m.prompt {
+	case promptAbort:
+		out.WriteString("\n" + color.Danger.Foreground("Abort the rebase?") + " " + m.promptKeys.choices() + "\n")
+	case promptExec:
+		out.WriteString("\nCommand to run: " + m.input.View() + "\n")
+	}
+
+	return out.String()
+}
+
+// todoStyle colors a todo command by what it does to the commit.
+func todoStyle(command string) func(...string) string {
+	switch command {
+	case git.Pick:
+		return gloss.NewStyle().Render
+	case git.Reword:
+		return color.TodoReword.Foreground
+	case git.Edit:
+		return color.TodoEdit.Foreground
+	case git.Squash, git.Fixup:
+		return color.TodoSquash.Foreground
+	case git.Drop:
+		return color.TodoDrop.Foreground
+	}
+	return color.TodoExec.Foreground
+}
+
+func (m rebaseModel) viewPreview() string {
+	var title string
+	if len(m.todo) > 0 {
+		l := m.todo[m.selected]
+		title = color.Note.Foreground(gloss.NewStyle().MaxWidth(m.preview.Width).Render(strings.TrimSpace(l.OID + " " + l.Text)))
+	}
+	return previewStyle.Render(title + "\n" + m.preview.View())
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cv4x/got/git"
+)
+
+func TestRebaseEdit(t *testing.T) {
+	t.Parallel()
+
+	m := newRebaseModel(git.RepoState{Ref: "0123456789abcdef"},
+		git.ParseTodo("pick 1111111 one\npick 2222222 two\npick 3333333 three\n"))
+	// move the first commit down, fold it into the one now before it, reword
+	// the last and run a command after it
+	for _, msg := range press("J", "f", "r", "x", "make", "enter") {
+		next, _ := m.Update(msg)
+		m = next.(rebaseModel)
+	}
+
+	want := []git.TodoLine{
+		{Command: git.Pick, OID: "2222222", Text: "two"},
+		{Command: git.Fixup, OID: "1111111", Text: "one"},
+		{Command: git.Reword, OID: "3333333", Text: "three"},
+		{Command: git.Exec, Text: "make"},
+	}
+	if !reflect.DeepEqual(m.todo, want) {
+		t.Errorf("todo = %+v, want %+v", m.todo, want)
+	}
+	if !m.changed {
+		t.Errorf("edited list is not marked as changed")
+	}
+}
+
+func TestCheckTodo(t *testing.T) {
+	t.Parallel()
+
+	todo := git.ParseTodo("drop 1111111 one\nsquash 2222222 two\npick 3333333 three\n")
+	if err := checkTodo(todo); err == nil {
+		t.Errorf("squash onto a dropped commit was accepted")
+	}
+	todo[0].Command = git.Pick
+	if err := checkTodo(todo); err != nil {
+		t.Errorf("valid list was rejected: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+type reviewKeyMap struct {
+	Up      key.Binding
+	Down    key.Binding
+	Confirm key.Binding
+	Back    key.Binding
+	Quit    key.Binding
+}
+
+func (k reviewKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Back}
+}
+
+func (k reviewKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down},
+		{k.Confirm, k.Back},
+	}
+}
+
+var reviewKeys = reviewKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "scroll up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "scroll down   "),
+	),
+	Confirm: key.NewBinding(
+		key.WithKeys("enter", "y"),
+		key.WithHelp("ent/y", "apply   "),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("q", "esc", "n"),
+		key.WithHelp("q", "back"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+	),
+}
+
+// confirmPolicy says when submitting shows the pending actions for review
+// first. It is set by got.confirm, which a team can share through a config
+// file included by everyone.
+type confirmPolicy string
+
+const (
+	confirmAlways      confirmPolicy = "always"
+	confirmDestructive confirmPolicy = "destructive"
+	confirmNever       confirmPolicy = "never"
+)
+
+// destructive reports whether an action throws away changes in the worktree.
+// got saves them for got undo, but that is no reason to be casual about it.
+func (a action) destructive() bool {
+	return a == restore || a == ours || a == theirs
+}
+
+// reviewOrder is the order the actions are listed in, the destructive ones
+// last, right above the question.
+var reviewOrder = []action{resolve, stage, unstage, stash, ours, theirs, restore}
+
+type reviewItem struct {
+	file  file
+	lines git.LineCount
+	// counted is unset where there is nothing to count, e.g. for conflicts.
+	counted bool
+}
+
+// reviewNext is what confirming the review goes on to do.
+type reviewNext int
+
+const (
+	// reviewQuit applies the pending actions and quits.
+	reviewQuit reviewNext = iota
+	// reviewStay applies them without quitting.
+	reviewStay
+	// reviewCommit applies them and commits.
+	reviewCommit
+	// reviewContinue applies them and continues the operation in progress.
+	reviewContinue
+)
+
+// reviewView lists the files each pending action applies to. Destructive
+// actions have to be confirmed once more after the review.
+type reviewView struct {
+	items       map[action][]reviewItem
+	destructive bool
+	asking      bool
+	next        reviewNext
+}
+
+// needsReview reports whether submitting should show the review first.
+func (m model) needsReview() bool {
+	switch m.confirm {
+	case confirmNever:
+		return false
+	case confirmAlways:
+		return m.pendingSummary() != ""
+	}
+	for _, f := range m.files {
+		for a, on := range f.pending {
+			if on && a.destructive() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *model) openReview(next reviewNext) {
+	unstaged, err := m.repo.NumStat(false)
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	staged, err := m.repo.NumStat(true)
+	if err != nil {
+		m.fail(err)
+		return
+	}
+
+	v := reviewView{items: make(map[action][]reviewItem), next: next}
+	for _, f := range m.files {
+		for _, a := range reviewOrder {
+			if !f.pending[a] {
+				continue
+			}
+			item := reviewItem{file: f}
+			switch f.category {
+			case Staged:
+				item.lines, item.counted = staged[f.path]
+			case Unstaged:
+				item.lines, item.counted = unstaged[f.path]
+			case Untracked:
+				// untracked files are not in the diff, and are added whole
+				if data, err := os.ReadFile(m.rootdir + "/" + f.path); err == nil {
+					item.lines.Added = bytes.Count(data, []byte("\n"))
+					item.lines.Binary = bytes.IndexByte(data, 0) >= 0
+					item.counted = true
+				}
+			}
+			v.items[a] = append(v.items[a], item)
+			v.destructive = v.destructive || a.destructive()
+		}
+	}
+
+	m.mode = modeReview
+	m.review = v
+	m.resize()
+	m.viewport.SetContent(m.viewContent())
+	m.viewport.GotoTop()
+}
+
+// closeReview goes back to where the review was opened from.
+func (m *model) closeReview() {
+	if m.review.next == reviewCommit {
+		m.mode = modeCommit
+		m.resize()
+		return
+	}
+	m.mode = modeFiles
+	m.resize()
+	m.viewport.SetContent(m.viewContent())
+	m.scroll()
+}
+
+func (m model) updateReview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.review.asking {
+		switch {
+		case key.Matches(msg, m.promptKeys.Yes):
+			return m.confirmReview()
+		case key.Matches(msg, m.promptKeys.No, m.promptKeys.Cancel, m.reviewKeys.Back):
+			m.review.asking = false
+		case key.Matches(msg, m.promptKeys.Quit):
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, m.reviewKeys.Up):
+		m.viewport.LineUp(1)
+	case key.Matches(msg, m.reviewKeys.Down):
+		m.viewport.LineDown(1)
+	case key.Matches(msg, m.reviewKeys.Confirm):
+		if !m.review.destructive {
+			return m.confirmReview()
+		}
+		m.review.asking = true
+		m.viewport.SetContent(m.viewContent())
+		m.viewport.GotoBottom()
+	case key.Matches(msg, m.reviewKeys.Back):
+		m.closeReview()
+	case key.Matches(msg, m.reviewKeys.Quit):
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// confirmReview goes on with what the review was opened for.
+func (m model) confirmReview() (tea.Model, tea.Cmd) {
+	switch m.review.next {
+	case reviewCommit:
+		m.closeReview()
+		return m.commitPending()
+	case reviewContinue:
+		m.closeReview()
+		return m.continuePending()
+	}
+	return m.apply(m.review.next == reviewStay)
+}
+
+// apply carries out the pending actions and quits, or stays to show what went
+// wrong. With stay set, it stays either way, with the list brought up to date.
+func (m model) apply(stay bool) (tea.Model, tea.Cmd) {
+	err := m.process(m.files)
+	if err == nil && !stay {
+		return m, tea.Quit
+	}
+	if err != nil {
+		// some actions may have been applied before the failure
+		m.fail(err)
+	} else {
+		// the history holds actions on files that may be gone now
+		for _, f := range m.files {
+			clear(f.pending)
+		}
+		m.history = history{}
+	}
+	if err := m.reload(); err != nil {
+		m.fail(err)
+	}
+	if m.mode == modeReview {
+		m.closeReview()
+	} else {
+		m.scroll()
+	}
+	return m, nil
+}
+
+func (m model) viewReview() string {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+
+	var (
+		out     strings.Builder
+		discard int
+	)
+	for _, a := range reviewOrder {
+		items := m.review.items[a]
+		if len(items) == 0 {
+			continue
+		}
+		name := a.String()
+		title := fmt.Sprintf("%s%s (%d)", strings.ToUpper(name[:1]), name[1:], len(items))
+		if a.destructive() {
+			discard += len(items)
+		}
+		out.WriteString(m.getContentSeparator(title))
+
+		for _, item := range items {
+			var counts string
+			switch {
+			case !item.counted:
+			case item.lines.Binary:
+				counts = color.Note.Foreground("binary")
+			default:
+				counts = color.DiffAdded.Foreground(fmt.Sprintf("+%d", item.lines.Added)) + " " +
+					color.DiffDeleted.Foreground(fmt.Sprintf("-%d", item.lines.Deleted))
+			}
+			f := item.file
+			code := string(f.status)
+			if f.category == Conflicts {
+				code = f.conflict.Code()
+			}
+			text := code + " " + f.path
+			if runes, room := []rune(text), contentWidth-gloss.Width(counts)-2; len(runes) > room {
+				text = "…" + string(runes[max(0, len(runes)-room+1):])
+			}
+			if a.destructive() {
+				text = color.Danger.Foreground(text)
+			} else {
+				text = color.ByStatus(text, f.status, f.staged)
+			}
+			gap := max(1, contentWidth-gloss.Width(text)-gloss.Width(counts))
+			out.WriteString(text + strings.Repeat(" ", gap) + counts + "\n")
+		}
+	}
+
+	if m.review.asking {
+		question := fmt.Sprintf("Discard the changes to %d %s?", discard, plural(discard, "file", "files"))
+		out.WriteString("\n" + color.Danger.Foreground(question) + " " + m.promptKeys.choices() + "\n")
+		out.WriteString(color.Note.Foreground("got undo brings them back") + "\n")
+	}
+	return out.String()
+}
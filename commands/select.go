@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Several files can be selected to act on at once: files marked one by one,
+// and the range between the anchor of a visual selection and the cursor. Only
+// files that pass the filter are acted on.
+
+// selecting reports whether actions apply to the selection rather than to the
+// file under the cursor.
+func (m model) selecting() bool {
+	return m.anchor >= 0 || len(m.marks()) > 0
+}
+
+// marks returns the indices of the marked files that pass the filter.
+func (m model) marks() []int {
+	var idx []int
+	for i, f := range m.files {
+		if f.marked && m.visible(i) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// inRange reports whether the file at index i lies between the anchor and the
+// cursor.
+func (m model) inRange(i int) bool {
+	if m.anchor < 0 {
+		return false
+	}
+	return i >= min(m.anchor, m.selected) && i <= max(m.anchor, m.selected)
+}
+
+// targets returns the indices of the selected files.
+func (m model) targets() []int {
+	var idx []int
+	for i, f := range m.files {
+		if m.visible(i) && (f.marked || m.inRange(i)) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// bulk returns the files an action applies to when it is not just the file
+// under the cursor: the selection, or else the files in the folder under the
+// cursor.
+func (m model) bulk() ([]int, bool) {
+	if m.selecting() {
+		return m.targets(), true
+	}
+	if r, ok := m.current(); ok {
+		return r.files, true
+	}
+	return nil, false
+}
+
+// markFolder marks the files in a folder, or unmarks them if they all are
+// already.
+func (m *model) markFolder(r row) {
+	on := slices.ContainsFunc(r.files, func(i int) bool { return !m.files[i].marked })
+	for _, i := range r.files {
+		m.files[i].marked = on
+	}
+}
+
+// toggleVisual starts a range at the cursor, or ends the one in progress and
+// keeps its files marked.
+func (m *model) toggleVisual() {
+	if m.anchor < 0 {
+		m.anchor = m.selected
+		return
+	}
+	for _, i := range m.targets() {
+		m.files[i].marked = true
+	}
+	m.anchor = -1
+}
+
+func (m *model) clearSelection() {
+	for i := range m.files {
+		m.files[i].marked = false
+	}
+	m.anchor = -1
+}
+
+// selectCategory marks every file in the category, or unmarks them if they
+// all are already.
+func (m *model) selectCategory(c category) {
+	// keep what a range in progress selected
+	if m.anchor >= 0 {
+		m.toggleVisual()
+	}
+
+	all := true
+	for i, f := range m.files {
+		if f.category == c && m.visible(i) && !f.marked {
+			all = false
+		}
+	}
+	for i, f := range m.files {
+		if f.category == c && m.visible(i) {
+			m.files[i].marked = !all
+		}
+	}
+}
+
+// invertSelection marks the files that are not selected and unmarks the ones
+// that are.
+func (m *model) invertSelection() {
+	targets := m.targets()
+	m.anchor = -1
+	for i := range m.files {
+		if m.visible(i) {
+			m.files[i].marked = true
+		}
+	}
+	for _, i := range targets {
+		m.files[i].marked = false
+	}
+}
+
+// selectGlob marks the files whose path matches a glob pattern. Like in a
+// .gitignore, a pattern without a slash matches the name of the file in any
+// directory.
+func (m *model) selectGlob(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	n := 0
+	for i, f := range m.files {
+		name := f.path
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, name); ok && m.visible(i) {
+			m.files[i].marked = true
+			n++
+		}
+	}
+	if n == 0 {
+		return fmt.Errorf("no files match %s", pattern)
+	}
+	return nil
+}
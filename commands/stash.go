@@ -0,0 +1,385 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+type stashKeyMap struct {
+	Up         key.Binding
+	Down       key.Binding
+	Apply      key.Binding
+	Pop        key.Binding
+	Drop       key.Binding
+	Rename     key.Binding
+	Branch     key.Binding
+	ScrollUp   key.Binding
+	ScrollDown key.Binding
+	Quit       key.Binding
+}
+
+func (k stashKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Quit}
+}
+
+func (k stashKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Apply, k.Pop},
+		{k.Drop, k.Rename},
+		{k.Branch, k.Quit},
+	}
+}
+
+var stashKeys = stashKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "up   "),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "down   "),
+	),
+	Apply: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "apply   "),
+	),
+	Pop: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pop   "),
+	),
+	Drop: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "drop   "),
+	),
+	Rename: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "rename   "),
+	),
+	Branch: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "branch   "),
+	),
+	ScrollUp: key.NewBinding(
+		key.WithKeys("pgup", "ctrl+u"),
+		key.WithHelp("pgup", "scroll preview up   "),
+	),
+	ScrollDown: key.NewBinding(
+		key.WithKeys("pgdown", "ctrl+d"),
+		key.WithHelp("pgdn", "scroll preview down   "),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	),
+}
+
+// stashModel lists the stash entries with the diff of the selected one next to
+// or below the list.
+type stashModel struct {
+	frame
+	term     dimensions
+	keys     stashKeyMap
+	head     head
+	stashes  []git.Stash
+	selected int
+	preview  viewport.Model
+	diffs    []git.FileDiff
+	// loaded is the object ID of the entry shown in the preview
+	loaded  string
+	message string
+}
+
+func Stash(state git.RepoState, args []string) error {
+	flags("stash", args)
+
+	stashes, err := git.Stashes()
+	if err != nil {
+		return err
+	}
+	if len(stashes) == 0 {
+		fmt.Println("no stash entries")
+		return nil
+	}
+
+	model := stashModel{
+		frame:   newFrame(),
+		keys:    stashKeys,
+		head:    newHead(state),
+		stashes: stashes,
+	}
+
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		log.Fatalf("Fatal error: %v", err)
+	}
+	return nil
+}
+
+func (m stashModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m stashModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.message = ""
+		if m.prompt != promptNone {
+			return m.updatePrompt(msg)
+		}
+
+		current := m.stashes[m.selected]
+		switch {
+		case key.Matches(msg, m.keys.Up):
+			m.selected = (m.selected + len(m.stashes) - 1) % len(m.stashes)
+		case key.Matches(msg, m.keys.Down):
+			m.selected = (m.selected + 1) % len(m.stashes)
+		case key.Matches(msg, m.keys.Apply):
+			return m.finish(git.StashApply(current.Ref))
+		case key.Matches(msg, m.keys.Pop):
+			return m.finish(git.StashPop(current.Ref))
+		case key.Matches(msg, m.keys.Drop):
+			m.prompt = promptDrop
+		case key.Matches(msg, m.keys.Rename):
+			cmd = m.ask(promptRename, current.Message)
+		case key.Matches(msg, m.keys.Branch):
+			cmd = m.ask(promptBranch, "")
+		case key.Matches(msg, m.keys.ScrollUp):
+			m.preview.HalfViewUp()
+		case key.Matches(msg, m.keys.ScrollDown):
+			m.preview.HalfViewDown()
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.term.width = msg.Width
+		m.term.height = msg.Height
+		m.resize()
+
+	default:
+		// keep the cursor of the prompt blinking
+		if m.prompt != promptNone {
+			m.input, cmd = m.input.Update(msg)
+		}
+	}
+
+	m.syncPreview()
+	return m, cmd
+}
+
+func (m stashModel) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	current := m.stashes[m.selected]
+
+	p, value, cmd := m.answer(msg)
+	switch p {
+	case promptNone:
+		return m, cmd
+	case promptDrop:
+		m.keep(git.StashDrop(current.Ref), m.selected)
+	case promptBranch:
+		return m.finish(git.StashBranch(value, current.Ref))
+	case promptRename:
+		// the renamed entry is stored again at the top
+		m.keep(git.StashRename(current.Ref, value), 0)
+	}
+
+	return m.stay()
+}
+
+// finish quits after an action that brings the stash into the worktree, or
+// stays to show what went wrong. A failed apply may still have changed the
+// worktree and the list, e.g. when it ran into conflicts.
+func (m stashModel) finish(err error) (tea.Model, tea.Cmd) {
+	if err == nil {
+		return m, tea.Quit
+	}
+	m.keep(err, m.selected)
+	return m.stay()
+}
+
+// stay carries on after the list was reloaded, unless there is nothing left to
+// show.
+func (m stashModel) stay() (tea.Model, tea.Cmd) {
+	if len(m.stashes) == 0 {
+		return m, tea.Quit
+	}
+	m.syncPreview()
+	return m, nil
+}
+
+// keep reloads the list after an action that stays in the view, moving the
+// cursor to selected, and shows the error of the action if there is one.
+func (m *stashModel) keep(err error, selected int) {
+	if err != nil {
+		m.fail(err)
+	}
+
+	stashes, err := git.Stashes()
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	m.stashes = stashes
+	m.selected = min(selected, max(0, len(stashes)-1))
+	m.loaded = ""
+}
+
+func (m *stashModel) fail(err error) {
+	m.message = err.Error()
+}
+
+func (m *stashModel) resize() {
+	if m.term.width == 0 {
+		return
+	}
+
+	list, pane := split(m.term, m.layout())
+	fitPane(&m.preview, pane)
+	m.loaded = ""
+	m.fit(list, gloss.Height(m.viewHeader()), gloss.Height(m.viewFooter()), 20)
+}
+
+func (m stashModel) layout() layout {
+	return paneLayout(m.term)
+}
+
+// syncPreview loads the diff of the selected entry if it is not the one
+// already shown.
+func (m *stashModel) syncPreview() {
+	if m.layout() == previewHidden || len(m.stashes) == 0 {
+		return
+	}
+	current := m.stashes[m.selected]
+	if current.OID == m.loaded {
+		return
+	}
+
+	diffs, err := git.StashDiff(current.Ref)
+	if err != nil {
+		m.fail(err)
+	}
+	m.diffs = diffs
+	m.loaded = current.OID
+
+	var out strings.Builder
+	for _, d := range m.diffs {
+		out.WriteString(color.DiffFile.Foreground(gloss.NewStyle().MaxWidth(m.preview.Width).Render(d.Path())) + "\n")
+		writeDiff(&out, d, m.preview.Width)
+	}
+	m.preview.SetContent(out.String())
+	m.preview.GotoTop()
+}
+
+func (m stashModel) View() string {
+	if s, ok := m.tooSmall(m.keys.Quit); ok {
+		return s
+	}
+
+	m.viewport.SetContent(m.viewContent())
+	m.keepInView(m.selected)
+
+	list := renderFrame(m.xy.height, m.viewHeader(), m.viewport, m.viewFooter())
+	switch m.layout() {
+	case previewRight:
+		return gloss.JoinHorizontal(gloss.Top, list, m.viewPreview())
+	case previewBelow:
+		return gloss.JoinVertical(gloss.Left, list, m.viewPreview())
+	}
+	return list
+}
+
+func (m stashModel) viewHeader() string {
+	subtitle := fmt.Sprintf("%d stashed", len(m.stashes))
+	return renderHeader(m.viewport.Width, m.head.title(), subtitle)
+}
+
+func (m stashModel) viewFooter() string {
+	return renderFooter(m.viewport.Width, m.help, m.keys, m.message)
+}
+
+func (m stashModel) viewContent() string {
+	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
+
+	var out strings.Builder
+	for i, s := range m.stashes {
+		cursor := "   "
+		if i == m.selected {
+			cursor = color.Cursor.Foreground(" ◈ ")
+		}
+		date := color.Date.Foreground(relativeTime(s.Date))
+		ref := color.Ref.Foreground(s.Ref)
+
+		room := contentWidth - gloss.Width(cursor) - gloss.Width(ref) - gloss.Width(date) - 2
+		message := s.Message
+		if gloss.Width(message) > room {
+			message = gloss.NewStyle().MaxWidth(max(0, room-1)).Render(message) + "…"
+		}
+		fill := strings.Repeat(" ", max(1, room-gloss.Width(message)+1))
+		out.WriteString(cursor + ref + " " + message + fill + date + "\n")
+	}
+
+	if m.prompt != promptNone {
+		current := m.stashes[m.selected]
+		out.WriteString("\n")
+		switch m.prompt {
+		case promptDrop:
+			out.WriteString(color.Danger.Foreground("Drop "+current.Ref+"?") + " " + m.promptKeys.choices())
+		case promptRename:
+			out.WriteString("Rename " + current.Ref + ": " + m.input.View())
+		case promptBranch:
+			out.WriteString("Branch from " + current.Ref + ": " + m.input.View())
+		}
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+func (m stashModel) viewPreview() string {
+	var title string
+	if len(m.stashes) > 0 {
+		title = color.Note.Foreground(m.stashes[m.selected].Ref)
+	}
+	return previewStyle.Render(title + "\n" + m.preview.View())
+}
+
+// relativeTime describes how long ago t was, e.g. "3 hours ago", in the
+// largest unit that fits.
+func relativeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := time.Since(t)
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, u := range units {
+		if n := int(d / u.size); n > 0 {
+			if n == 1 {
+				return fmt.Sprintf("1 %s ago", u.name)
+			}
+			return fmt.Sprintf("%d %ss ago", n, u.name)
+		}
+	}
+	return "just now"
+}
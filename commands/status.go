@@ -1,21 +1,24 @@
 package commands
 
 import (
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"math"
-	"os"
 	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
-	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	gloss "github.com/charmbracelet/lipgloss"
 	"github.com/cv4x/got/color"
 	"github.com/cv4x/got/git"
+	"github.com/cv4x/got/watch"
 )
 
 var (
@@ -33,50 +36,218 @@ type head struct {
 	name     string
 	ref      string
 	isbranch bool
+	op       git.Operation
+}
+
+func newHead(state git.RepoState) head {
+	return head{
+		name:     cmp.Or(state.Branch, state.Ref),
+		ref:      state.Ref,
+		isbranch: state.Branch != "",
+		op:       state.Operation,
+	}
+}
+
+// moved reports whether HEAD points to another branch or commit than before.
+// The commit may be abbreviated in either.
+func (h head) moved(before head) bool {
+	return h.name != before.name ||
+		!strings.HasPrefix(h.ref, before.ref) && !strings.HasPrefix(before.ref, h.ref)
+}
+
+// title describes what HEAD points to, or the operation in progress, for the
+// header of a view.
+func (h head) title() string {
+	if h.op.Kind != git.OpNone {
+		return h.opTitle()
+	}
+	if h.isbranch {
+		return fmt.Sprintf("On branch %s (%s)", color.HeadBranch.Foreground(h.name), color.HeadCommit.Foreground(h.ref[:7]))
+	}
+	return "Detached at " + color.HeadCommit.Foreground(h.ref[:7])
+}
+
+// opTitle describes the operation in progress, e.g. "Rebasing main onto
+// origin/main (2/5)".
+func (h head) opTitle() string {
+	op := h.op
+	name := color.HeadBranch.Foreground
+	branch := op.Head
+	if branch == "" && h.isbranch {
+		branch = h.name
+	}
+
+	var s string
+	switch op.Kind {
+	case git.OpRebase:
+		s = "Rebasing"
+		if branch != "" {
+			s += " " + name(branch)
+		}
+		if op.Onto != "" {
+			s += " onto " + name(op.Onto)
+		}
+	case git.OpApplyMailbox:
+		s = "Applying patches"
+		if branch != "" {
+			s += " to " + name(branch)
+		}
+	case git.OpMerge:
+		s = "Merging " + name(op.Commit)
+		if branch != "" {
+			s += " into " + name(branch)
+		}
+	case git.OpCherryPick:
+		s = "Cherry-picking " + name(op.Commit)
+		if branch != "" {
+			s += " onto " + name(branch)
+		}
+	case git.OpRevert:
+		s = "Reverting " + name(op.Commit)
+		if branch != "" {
+			s += " on " + name(branch)
+		}
+	case git.OpBisect:
+		s = "Bisecting"
+		if branch != "" {
+			s += " from " + name(branch)
+		}
+	}
+	if op.Total > 0 {
+		s += " " + color.Progress.Foreground(fmt.Sprintf("(%d/%d)", op.Step, op.Total))
+	}
+	return color.Operation.Foreground("● ") + s
 }
 
 type category string
 
 const (
+	Conflicts category = "Conflicts"
 	Staged    category = "Staged"
 	Unstaged  category = "Unstaged"
 	Untracked category = "Untracked"
 )
 
+type mode byte
+
+const (
+	modeFiles mode = iota
+	modeHunks
+	modeCommit
+	modeConflict
+	modeReview
+)
+
 type action byte
 
 const (
 	stage action = iota
 	unstage
 	restore
+	stash
+	ours
+	theirs
+	resolve
 )
 
+func (a action) String() string {
+	switch a {
+	case stage:
+		return "stage"
+	case unstage:
+		return "unstage"
+	case restore:
+		return "restore"
+	case stash:
+		return "stash"
+	case ours:
+		return "take ours"
+	case theirs:
+		return "take theirs"
+	case resolve:
+		return "mark resolved"
+	}
+	return ""
+}
+
 type file struct {
 	category category
 	path     string
 	staged   bool
 	status   git.StatusCode
 	extra    string
+	conflict git.Conflict
 	pending  map[action]bool
+	// marked is set on files picked one by one for a bulk action.
+	marked bool
+}
+
+// resolution returns the pending action that resolves a conflict, if any.
+func (f file) resolution() (action, bool) {
+	for _, a := range []action{ours, theirs, resolve} {
+		if f.pending[a] {
+			return a, true
+		}
+	}
+	return 0, false
 }
 
 func (f file) position() gloss.Position {
+	if _, ok := f.resolution(); ok {
+		return gloss.Right
+	}
 	if f.pending[stage] || (f.staged && !f.pending[unstage]) {
 		return gloss.Right
 	}
 	return gloss.Left
 }
 
-func (f file) text(maxWidth int) string {
-	text := string(f.status) + " " + f.path
+// text renders the file as a line of the list, showing its path as name, with
+// the runes of name at positions highlighted as matches of the filter.
+func (f file) text(name string, maxWidth int, positions []int) string {
+	prefix := string(f.status) + " "
+	text := prefix + name
+	if f.category == Conflicts {
+		note := f.conflict.String()
+		if a, ok := f.resolution(); ok {
+			note = a.String()
+		}
+		prefix = f.conflict.Code() + " "
+		text = prefix + name + " (" + note + ")"
+	}
+
+	// the positions count runes of the path, so shift them past the prefix and
+	// whatever is cut off the front
+	shift := utf8.RuneCountInString(prefix)
 	// TODO truncate on file separators where possible
-	if gloss.Width(text) > maxWidth-8 {
-		text = "…" + text[max(0, gloss.Width(text)-maxWidth+8):]
+	if runes := []rune(text); len(runes) > maxWidth-8 {
+		cut := max(0, len(runes)-maxWidth+8)
+		text = "…" + string(runes[cut:])
+		shift -= cut - 1
 	}
-	if f.pending[restore] {
-		return color.BrightBlack.Foreground(text)
+	marked := make([]int, 0, len(positions))
+	for _, p := range positions {
+		if p+shift > 0 {
+			marked = append(marked, p+shift)
+		}
 	}
-	return color.ByStatus(text, f.status, f.staged)
+
+	var style func(...string) string
+	switch {
+	case f.pending[restore]:
+		style = color.Restore.Foreground
+	case f.pending[stash]:
+		style = color.Stash.Foreground
+	default:
+		if _, ok := f.resolution(); ok {
+			style = color.Resolved.Foreground
+		} else {
+			style = func(s ...string) string {
+				return color.ByStatus(strings.Join(s, ""), f.status, f.staged)
+			}
+		}
+	}
+	return highlight(text, marked, color.Match.Foreground, style)
 }
 
 type dimensions struct {
@@ -85,27 +256,62 @@ type dimensions struct {
 }
 
 type keyMap struct {
-	Up     key.Binding
-	Down   key.Binding
-	Left   key.Binding
-	Right  key.Binding
-	Top    key.Binding
-	Bottom key.Binding
-	Submit key.Binding
-	Quit   key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Left       key.Binding
+	Right      key.Binding
+	Top        key.Binding
+	Bottom     key.Binding
+	Hunks      key.Binding
+	Preview    key.Binding
+	Commit     key.Binding
+	Stash      key.Binding
+	Ours       key.Binding
+	Theirs     key.Binding
+	Continue   key.Binding
+	Skip       key.Binding
+	Abort      key.Binding
+	Filter     key.Binding
+	Next       key.Binding
+	Prev       key.Binding
+	Mark       key.Binding
+	Visual     key.Binding
+	ExtendUp   key.Binding
+	ExtendDown key.Binding
+	All        key.Binding
+	Invert     key.Binding
+	Glob       key.Binding
+	Tree       key.Binding
+	Fold       key.Binding
+	Undo       key.Binding
+	Redo       key.Binding
+	ScrollUp   key.Binding
+	ScrollDown key.Binding
+	Submit     key.Binding
+	Apply      key.Binding
+	Quit       key.Binding
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
 	return []key.Binding{k.Quit}
 }
 
+// FullHelp leaves out the conflict and operation actions while they do not
+// apply, so the columns are packed from the bindings that are enabled. The
+// actions that matter most come first, in case the help is cut off.
 func (k keyMap) FullHelp() [][]key.Binding {
-	return [][]key.Binding{
-		{k.Left},
-		{k.Right},
-		{k.Submit},
-		{k.Quit},
-	}
+	return helpColumns(
+		k.Left, k.Right,
+		k.Ours, k.Theirs,
+		k.Continue, k.Abort, k.Skip,
+		k.Hunks, k.Preview,
+		k.Commit, k.Submit, k.Apply,
+		k.Filter, k.Next,
+		k.Stash, k.Quit,
+		k.Undo, k.Tree, k.Fold,
+		k.Visual, k.Mark,
+		k.All, k.Invert, k.Glob,
+	)
 }
 
 var keys = keyMap{
@@ -133,10 +339,113 @@ var keys = keyMap{
 		key.WithKeys("end"),
 		key.WithHelp("end", "bottom   "),
 	),
+	Hunks: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "hunks   "),
+	),
+	Preview: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "preview   "),
+	),
+	Commit: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "commit   "),
+	),
+	Stash: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "stash   "),
+	),
+	Ours: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "ours   "),
+	),
+	Theirs: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "theirs   "),
+	),
+	Continue: key.NewBinding(
+		key.WithKeys("C"),
+		key.WithHelp("C", "continue   "),
+	),
+	Skip: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "skip   "),
+	),
+	Abort: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "abort   "),
+	),
+	Filter: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "filter   "),
+	),
+	Next: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n/N", "match   "),
+		key.WithDisabled(),
+	),
+	Prev: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithDisabled(),
+	),
+	Mark: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "mark   "),
+	),
+	Visual: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "range   "),
+	),
+	ExtendUp: key.NewBinding(
+		key.WithKeys("shift+up", "K"),
+	),
+	ExtendDown: key.NewBinding(
+		key.WithKeys("shift+down", "J"),
+	),
+	All: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "mark category   "),
+	),
+	Invert: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "invert marks   "),
+	),
+	Glob: key.NewBinding(
+		key.WithKeys("*"),
+		key.WithHelp("*", "mark glob   "),
+	),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u/^r", "undo/redo   "),
+	),
+	Redo: key.NewBinding(
+		key.WithKeys("ctrl+r", "U"),
+	),
+	Tree: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "tree   "),
+	),
+	Fold: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("z", "fold   "),
+		key.WithDisabled(),
+	),
+	ScrollUp: key.NewBinding(
+		key.WithKeys("pgup", "ctrl+u"),
+		key.WithHelp("pgup", "scroll preview up   "),
+	),
+	ScrollDown: key.NewBinding(
+		key.WithKeys("pgdown", "ctrl+d"),
+		key.WithHelp("pgdn", "scroll preview down   "),
+	),
 	Submit: key.NewBinding(
 		key.WithKeys("enter", "y"),
 		key.WithHelp("ent/y", "confirm   "),
 	),
+	Apply: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "apply   "),
+	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "esc", "ctrl+c"),
 		key.WithHelp("q", "quit"),
@@ -144,40 +453,125 @@ var keys = keyMap{
 }
 
 type model struct {
-	xy       dimensions
-	viewport viewport.Model
-	keys     keyMap
-	help     help.Model
-	ready    bool
-	clean    bool
-	ahead    int
-	behind   int
-	head     head
-	rootdir  string
-	files    []file
-	selected int
-}
-
-func Status(state git.RepoState, args []string) {
-	args = flags(args)
-
-	model := prepare(state)
+	frame
+	term         dimensions
+	keys         keyMap
+	hunkKeys     hunkKeyMap
+	commitKeys   commitKeyMap
+	conflictKeys conflictKeyMap
+	reviewKeys   reviewKeyMap
+	mode         mode
+	clean        bool
+	ahead        int
+	behind       int
+	gone         bool
+	head         head
+	rootdir      string
+	repo         git.Backend
+	files        []file
+	selected     int
+	anchor       int
+	filter       textinput.Model
+	filtering    bool
+	matches      map[int][]int
+	tree         bool
+	folder       string
+	collapsed    map[string]bool
+	history      history
+	hunks        hunkView
+	conflicts    conflictView
+	review       reviewView
+	confirm      confirmPolicy
+	preview      previewPane
+	watcher      *watch.Watcher
+	commit       commitView
+	message      string
+}
+
+func Status(state git.RepoState, args []string) error {
+	args = flags("status", args)
+
+	model, err := prepare(git.CLI{}, state)
+	if err != nil {
+		return err
+	}
+	// an operation may still have to be continued or aborted
+	if model.clean && model.head.op.Kind == git.OpNone {
+		fmt.Println("nothing to commit, working tree clean")
+		return nil
+	}
+
+	gitDir, err := model.repo.GitDir()
+	if err != nil {
+		return err
+	}
+	ignored, err := model.repo.IgnoredDirs(model.rootdir)
+	if err != nil {
+		return err
+	}
+	model.watcher = watch.New(model.rootdir, gitDir, ignored)
+	defer model.watcher.Close()
+
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		log.Fatalf("Fatal error: %v", err)
 	}
+	return nil
+}
+
+// aheadBehindMsg delivers the result of counting commits against the upstream,
+// which runs in the background so it does not delay startup.
+type aheadBehindMsg struct {
+	branch   string
+	tracking git.Tracking
+	err      error
+}
+
+func aheadBehind(repo git.Backend, branch string) tea.Cmd {
+	return func() tea.Msg {
+		tracking, err := repo.AheadBehind(branch)
+		return aheadBehindMsg{branch, tracking, err}
+	}
+}
+
+// changedMsg is sent when the worktree or the index changed.
+type changedMsg struct{}
+
+func waitForChanges(w *watch.Watcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		if _, ok := <-w.Changes; !ok {
+			return nil
+		}
+		return changedMsg{}
+	}
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{waitForChanges(m.watcher)}
+	if m.head.isbranch {
+		cmds = append(cmds, aheadBehind(m.repo, m.head.name))
+	}
+	return tea.Batch(cmds...)
 }
 
-func (m model) process(files []file) {
+func (m model) process(files []file) error {
 	// TODO make this more declarative
 	tounstage := make([]string, 0, len(files))
 	toadd := make([]string, 0, len(files))
 	torestore := make([]string, 0, len(files))
+	tostash := make([]string, 0, len(files))
+	toresolve := make([]string, 0, len(files))
+	totake := make([]file, 0, len(files))
 	for _, v := range files {
+		switch {
+		case v.pending[ours], v.pending[theirs]:
+			totake = append(totake, v)
+		case v.pending[resolve]:
+			toresolve = append(toresolve, m.rootdir+"/"+v.path)
+		}
 		if v.pending[unstage] {
 			tounstage = append(tounstage, m.rootdir+"/"+v.path)
 		}
@@ -187,147 +581,393 @@ func (m model) process(files []file) {
 		if v.pending[restore] {
 			torestore = append(torestore, m.rootdir+"/"+v.path)
 		}
+		// a path that is both staged and unstaged is marked in both categories
+		if v.pending[stash] && !slices.Contains(tostash, m.rootdir+"/"+v.path) {
+			tostash = append(tostash, m.rootdir+"/"+v.path)
+		}
+	}
+
+	// save what unstaging, restoring and taking a side discard; the worktree
+	// goes last, as got undo brings back the latest snapshot first
+	var dropped []string
+	for _, v := range files {
+		if v.pending[unstage] && m.changedInWorktree(v.path) {
+			dropped = append(dropped, m.rootdir+"/"+v.path)
+		}
+	}
+	if len(dropped) > 0 {
+		if err := m.repo.SnapshotStaged(m.snapshotMessage("unstage", dropped), dropped...); err != nil {
+			return err
+		}
+	}
+	discard := slices.Clone(torestore)
+	for _, v := range totake {
+		discard = append(discard, m.rootdir+"/"+v.path)
+	}
+	if len(discard) > 0 {
+		if err := m.repo.Snapshot(m.snapshotMessage("discard", discard), discard...); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range totake {
+		side := git.Ours
+		if v.pending[theirs] {
+			side = git.Theirs
+		}
+		if err := m.repo.Take(m.rootdir+"/"+v.path, v.conflict, side); err != nil {
+			return err
+		}
+	}
+	if len(toresolve) > 0 {
+		if err := m.repo.MarkResolved(toresolve...); err != nil {
+			return err
+		}
+	}
+	// stashing first leaves nothing for other actions on the same paths to do
+	if len(tostash) > 0 {
+		if err := m.repo.StashPush("", tostash...); err != nil {
+			return err
+		}
 	}
 	if len(tounstage) > 0 {
-		git.Unstage(tounstage...)
+		if err := m.repo.Unstage(tounstage...); err != nil {
+			return err
+		}
 	}
 	if len(toadd) > 0 {
-		git.Add(toadd...)
+		if err := m.repo.Add(toadd...); err != nil {
+			return err
+		}
 	}
 	if len(torestore) > 0 {
-		git.Restore(torestore...)
+		if err := m.repo.Restore(torestore...); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
+// changedInWorktree reports whether the worktree content of a path differs from
+// the index, so that its staged changes are nowhere else.
+func (m model) changedInWorktree(path string) bool {
+	return slices.ContainsFunc(m.files, func(f file) bool {
+		return f.category == Unstaged && f.path == path
+	})
+}
+
+// snapshotMessage describes what a snapshot of paths was taken for, as listed
+// by got undo -list.
+func (m model) snapshotMessage(verb string, paths []string) string {
+	if len(paths) == 1 {
+		return verb + " " + strings.TrimPrefix(paths[0], m.rootdir+"/")
+	}
+	return fmt.Sprintf("%s %d files", verb, len(paths))
+}
+
+// Update handles a message, and counts the commits against the upstream again
+// once HEAD has moved, as by a commit or a checkout in another terminal.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	n, ok := next.(model)
+	if !ok || !n.head.moved(m.head) {
+		return next, cmd
+	}
+	if n.head.name != m.head.name {
+		n.ahead, n.behind, n.gone = 0, 0, false
+	}
+	if n.head.isbranch {
+		cmd = tea.Batch(cmd, aheadBehind(n.repo, n.head.name))
+	}
+	return n, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		cmd  tea.Cmd
 		cmds []tea.Cmd
 	)
 
-	scroll := func() {
-		mid := m.viewport.VisibleLineCount() / 2
-		max := m.viewport.TotalLineCount()
-		if m.selected < mid {
-			m.viewport.GotoTop()
-		} else if m.selected > max-mid {
-			m.viewport.GotoBottom()
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.message = ""
+		switch m.mode {
+		case modeHunks:
+			return m.updateHunks(msg)
+		case modeCommit:
+			return m.updateCommit(msg)
+		case modeConflict:
+			return m.updateConflict(msg)
+		case modeReview:
+			return m.updateReview(msg)
+		}
+		if m.prompt != promptNone {
+			return m.updatePrompt(msg)
+		}
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
+		if kind := m.head.op.Kind; kind != git.OpNone {
+			switch {
+			case key.Matches(msg, m.keys.Continue) && kind.CanContinue():
+				if m.needsReview() {
+					m.openReview(reviewContinue)
+					return m, nil
+				}
+				return m.continuePending()
+			case key.Matches(msg, m.keys.Skip) && kind.CanSkip():
+				m.operate(m.repo.Skip)
+				return m, nil
+			case key.Matches(msg, m.keys.Abort):
+				m.prompt = promptAbort
+				return m, nil
+			}
+		}
+		if m.clean {
+			if key.Matches(msg, m.keys.Quit) {
+				return m, tea.Quit
+			}
+			break
 		}
 
-		percentpos := float64(m.selected) / float64((len(m.files) - 1))
-		scrollto := int(float64(m.viewport.TotalLineCount()) * percentpos)
-		m.viewport.SetYOffset(scrollto - mid)
-	}
+		switch {
+		case key.Matches(msg, m.keys.Filter):
+			m.filtering = true
+			return m, m.filter.Focus()
+		case key.Matches(msg, m.keys.Undo):
+			m.undo()
+			return m, nil
+		case key.Matches(msg, m.keys.Redo):
+			m.redo()
+			return m, nil
+		case key.Matches(msg, m.promptKeys.Cancel) && m.selecting():
+			// the first esc drops the selection, the next one the filter
+			m.clearSelection()
+			return m, nil
+		case key.Matches(msg, m.promptKeys.Cancel) && m.matches != nil:
+			// the first esc clears the filter rather than quitting
+			m.clearFilter()
+			return m, nil
+		case len(m.rows()) == 0:
+			// nothing matches the filter
+			if key.Matches(msg, m.keys.Quit) {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
 
-	up := func() {
-		m.selected = (m.selected + len(m.files) - 1) % len(m.files)
-		scroll()
-	}
-	down := func() {
-		m.selected = (m.selected + 1) % len(m.files)
-		scroll()
-	}
-	to := func(pos int) {
-		m.selected = pos
-		scroll()
-	}
+		before := m.pendingState()
 
-	selectedFile := m.files[m.selected]
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
+		selectedFile := m.files[m.selected]
 		switch {
-		case key.Matches(msg, keys.Up):
-			up()
-		case key.Matches(msg, keys.Down):
-			down()
-		case key.Matches(msg, keys.Left):
-			if selectedFile.pending[stage] {
-				selectedFile.pending[stage] = false
-			} else if selectedFile.category != Untracked && !selectedFile.pending[restore] &&
-				(selectedFile.pending[unstage] || (!selectedFile.staged && !selectedFile.pending[restore])) {
-				selectedFile.pending[restore] = true
-			} else if selectedFile.staged && !selectedFile.pending[unstage] {
-				selectedFile.pending[unstage] = true
+		case key.Matches(msg, m.keys.Up), key.Matches(msg, m.keys.Prev):
+			m.move(-1)
+		case key.Matches(msg, m.keys.Down), key.Matches(msg, m.keys.Next):
+			m.move(1)
+		case key.Matches(msg, m.keys.Left), key.Matches(msg, m.keys.Right):
+			shift := m.shiftLeft
+			if key.Matches(msg, m.keys.Right) {
+				shift = m.shiftRight
+			}
+			if targets, ok := m.bulk(); ok {
+				for _, i := range targets {
+					shift(m.files[i])
+				}
+				m.clearSelection()
+			} else if shift(selectedFile) {
+				m.move(1)
+			}
+		case key.Matches(msg, m.keys.Top):
+			m.to(0)
+		case key.Matches(msg, m.keys.Bottom):
+			m.to(-1)
+		case key.Matches(msg, m.keys.Hunks):
+			if m.folder != "" {
+				m.fold()
+			} else if selectedFile.category == Conflicts {
+				m.openConflict()
 			} else {
+				m.openHunks()
+			}
+		case key.Matches(msg, m.keys.Commit):
+			cmd = m.openCommit()
+		case key.Matches(msg, m.keys.Stash):
+			targets, ok := m.bulk()
+			if !ok {
+				m.markStash(selectedFile.path, !selectedFile.pending[stash])
+				m.move(1)
+				break
+			}
+			// stash every file unless all of them already are
+			on := slices.ContainsFunc(targets, func(i int) bool { return !m.files[i].pending[stash] })
+			for _, i := range targets {
+				m.markStash(m.files[i].path, on)
+			}
+			m.clearSelection()
+		case key.Matches(msg, m.keys.Ours), key.Matches(msg, m.keys.Theirs):
+			a := ours
+			if key.Matches(msg, m.keys.Theirs) {
+				a = theirs
+			}
+			targets, ok := m.bulk()
+			if !ok {
+				if selectedFile.category == Conflicts {
+					resolveWith(selectedFile, a, !selectedFile.pending[a])
+					m.move(1)
+				}
+				break
+			}
+			var conflicts []file
+			for _, i := range targets {
+				if m.files[i].category == Conflicts {
+					conflicts = append(conflicts, m.files[i])
+				}
+			}
+			on := slices.ContainsFunc(conflicts, func(f file) bool { return !f.pending[a] })
+			for _, f := range conflicts {
+				resolveWith(f, a, on)
+			}
+			m.clearSelection()
+		case key.Matches(msg, m.keys.Mark):
+			if r, ok := m.current(); ok {
+				m.markFolder(r)
 				break
 			}
-			down()
-		case key.Matches(msg, keys.Right):
-			if selectedFile.pending[restore] {
-				selectedFile.pending[restore] = false
-			} else if selectedFile.staged && selectedFile.pending[unstage] {
-				selectedFile.pending[unstage] = false
-			} else if !selectedFile.staged && !selectedFile.pending[stage] {
-				selectedFile.pending[stage] = true
+			m.files[m.selected].marked = !selectedFile.marked
+			m.move(1)
+		case key.Matches(msg, m.keys.Tree):
+			m.toggleTree()
+		case key.Matches(msg, m.keys.Fold):
+			m.fold()
+		case key.Matches(msg, m.keys.Visual):
+			m.toggleVisual()
+		case key.Matches(msg, m.keys.ExtendUp), key.Matches(msg, m.keys.ExtendDown):
+			if m.anchor < 0 {
+				m.anchor = m.selected
+			}
+			if key.Matches(msg, m.keys.ExtendUp) {
+				m.move(-1)
 			} else {
+				m.move(1)
+			}
+		case key.Matches(msg, m.keys.All):
+			m.selectCategory(selectedFile.category)
+		case key.Matches(msg, m.keys.Invert):
+			m.invertSelection()
+		case key.Matches(msg, m.keys.Glob):
+			return m, m.ask(promptGlob, "")
+		case key.Matches(msg, m.keys.Preview):
+			m.preview.show = !m.preview.show
+			m.resize()
+		case key.Matches(msg, m.keys.ScrollUp):
+			m.preview.viewport.HalfViewUp()
+		case key.Matches(msg, m.keys.ScrollDown):
+			m.preview.viewport.HalfViewDown()
+		case key.Matches(msg, m.keys.Submit), key.Matches(msg, m.keys.Apply):
+			stay := key.Matches(msg, m.keys.Apply)
+			if stay && m.pendingSummary() == "" {
+				m.message = "nothing to apply"
 				break
 			}
-			down()
-		case key.Matches(msg, keys.Top):
-			to(0)
-		case key.Matches(msg, keys.Bottom):
-			to(len(m.files) - 1)
-		case key.Matches(msg, keys.Submit):
-			m.process(m.files)
-			return m, tea.Quit
-		case key.Matches(msg, keys.Quit):
+			if m.needsReview() {
+				next := reviewQuit
+				if stay {
+					next = reviewStay
+				}
+				m.openReview(next)
+				break
+			}
+			return m.apply(stay)
+		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 		}
+		m.record(before)
 
-	case tea.WindowSizeMsg:
-		newWidth := min(80, msg.Width)
-		viewportWidth := newWidth - 4
+	case aheadBehindMsg:
+		switch {
+		case msg.branch != m.head.name:
+			// counted for a branch that is no longer checked out
+		case errors.Is(msg.err, git.ErrNoUpstream):
+		case msg.err != nil:
+			m.fail(msg.err)
+		default:
+			m.ahead = msg.tracking.Ahead
+			m.behind = msg.tracking.Behind
+			m.gone = msg.tracking.Gone
+		}
 
-		m.xy.width = newWidth
-		m.xy.height = msg.Height
+	case editorMsg:
+		m.closeEditor(msg)
 
-		headerHeight := gloss.Height(m.viewHeader())
-		footerHeight := gloss.Height(m.viewFooter())
-		verticalMarginHeight := headerHeight + footerHeight
+	case continuedMsg:
+		m.operate(func(git.OpKind) error { return msg.err })
 
-		if !m.ready {
-			m.viewport = viewport.New(viewportWidth, msg.Height-verticalMarginHeight)
+	case changedMsg:
+		m.refresh()
+		cmd = waitForChanges(m.watcher)
 
-			m.viewport.YPosition = headerHeight
-			m.viewport.Style = m.viewport.Style.Padding(0, 2)
-			m.viewport.SetContent(m.viewContent())
-			m.ready = true
-		} else {
-			m.viewport.Width = viewportWidth
-			m.viewport.Height = msg.Height - verticalMarginHeight
-			m.help.Width = newWidth - 10
+	case tea.WindowSizeMsg:
+		m.term.width = msg.Width
+		m.term.height = msg.Height
+		m.resize()
+
+	default:
+		// keep the cursor of the commit message editor or the filter blinking
+		switch {
+		case m.mode == modeCommit:
+			m.commit.editor, cmd = m.commit.editor.Update(msg)
+		case m.prompt == promptGlob:
+			m.input, cmd = m.input.Update(msg)
+		case m.filtering:
+			m.filter, cmd = m.filter.Update(msg)
 		}
 	}
 
+	m.syncPreview()
+
 	cmds = append(cmds, cmd)
 	return m, tea.Batch(cmds...)
 }
 
-func (m model) View() string {
-	if m.xy.width < 40 || m.xy.height < 10 {
-		return gloss.NewStyle().Width(m.xy.width).Height(m.xy.height).Align(gloss.Center, gloss.Center).Render("Your terminal is too small.\nResize the terminal to proceed\nor press q/esc/ctrl+c to exit.")
+// resize lays out the file list, and the preview pane if it is shown, for the
+// current terminal size.
+func (m *model) resize() {
+	if m.term.width == 0 {
+		return
 	}
 
-	m.viewport.SetContent(m.viewContent())
-	main := fmt.Sprintf("%s\n%s\n%s",
-		m.viewHeader(),
-		m.viewport.View(),
-		m.viewFooter())
+	list, pane := split(m.term, m.layout())
+	if m.layout() != previewHidden {
+		m.preview.resize(pane)
+	}
+	m.fit(list, gloss.Height(m.viewHeader()), gloss.Height(m.viewFooter()), 20)
 
-	return gloss.JoinHorizontal(gloss.Center, leftPad.Render(m.viewSideBorder("╭", "│", "╰")), main, rightPad.Render(m.viewSideBorder("╮", "│", "╯")))
+	if m.mode == modeCommit {
+		m.resizeCommit()
+	}
 }
 
-func (m model) viewHeader() string {
-	var titleText string
-	if m.head.isbranch {
-		titleText = fmt.Sprintf("On branch %s (%s)", color.Blue.Foreground(m.head.name), color.Cyan.Foreground(m.head.ref[:7]))
-	} else {
-		titleText = "Detached at " + color.Yellow.Foreground(m.head.ref[:7])
+func (m model) View() string {
+	if s, ok := m.tooSmall(m.keys.Quit); ok {
+		return s
 	}
 
-	title := headerStyle.Render(titleText)
+	m.viewport.SetContent(m.viewContent())
+	// keep the prompt below the list in view
+	if m.prompt != promptNone {
+		m.viewport.GotoBottom()
+	}
+	list := renderFrame(m.xy.height, m.viewHeader(), m.viewport, m.viewFooter())
+	switch m.layout() {
+	case previewRight:
+		return gloss.JoinHorizontal(gloss.Top, list, m.viewPreview())
+	case previewBelow:
+		return gloss.JoinVertical(gloss.Left, list, m.viewPreview())
+	}
+	return list
+}
 
-	var subtitle string
+func (m model) viewHeader() string {
 	subtitleParts := make([]string, 0, 2)
 	if m.ahead > 0 {
 		subtitleParts = append(subtitleParts, fmt.Sprintf("%d ▲", m.ahead))
@@ -335,127 +975,199 @@ func (m model) viewHeader() string {
 	if m.behind > 0 {
 		subtitleParts = append(subtitleParts, fmt.Sprintf("▼ %d", m.behind))
 	}
-	if len(subtitleParts) > 0 {
-		subtitleText := strings.Join(subtitleParts, "")
-		subtitle = headerStyle.Render(subtitleText)
+	if m.gone {
+		subtitleParts = append(subtitleParts, "upstream gone")
 	}
+	subtitle := strings.Join(subtitleParts, "")
 
-	// if title overflows, truncate, reset color, add elipses, and re-render
-	maxTitleWidth := m.viewport.Width - gloss.Width(subtitle)
-	if gloss.Width(title) > maxTitleWidth {
-		titleText = titleText[:max(0, maxTitleWidth-2)] + color.White.Foreground("") + "…"
-		title = headerStyle.Render(titleText)
+	// the filter takes whatever room is left next to the title
+	title := m.head.title()
+	m.filter.Width = max(1, min(m.viewport.Width/2, m.viewport.Width-gloss.Width(title)-gloss.Width(subtitle)-14))
+	if m.selecting() {
+		subtitle = strings.TrimSpace(fmt.Sprintf("%d selected %s", len(m.targets()), subtitle))
 	}
-
-	fill := strings.Repeat("─", max(0, m.viewport.Width-gloss.Width(title)-gloss.Width(subtitle)-2))
-	return gloss.JoinHorizontal(gloss.Center, "─", title, fill, subtitle, "─")
-}
-
-func (m model) viewSideBorder(top, mid, bot string) string {
-	borderHeight := m.xy.height - 4
-
-	mid = mid + "\n"
-	// Not enough content to scroll, therefore no scrollbar
-	if m.viewport.TotalLineCount() == m.viewport.VisibleLineCount() {
-		return fmt.Sprintf("%s\n%s%s\n",
-			top,
-			strings.Repeat(mid, max(0, borderHeight)),
-			bot)
+	if filter := m.filterStatus(); filter != "" {
+		subtitle = strings.TrimSpace(filter + " " + subtitle)
 	}
 
-	scrollBar := "█\n█\n"
-	scrollBarHeight := gloss.Height(scrollBar) - 1
-	scrollPercent := m.viewport.ScrollPercent()
-
-	var scroll int
-	if scrollPercent == 0 {
-		scroll = 0
-	} else {
-		scroll = int(math.Floor(scrollPercent * float64(borderHeight-scrollBarHeight)))
-	}
-
-	return fmt.Sprintf("%s\n%s%s%s%s\n",
-		top,
-		strings.Repeat(mid, max(0, scroll)),
-		scrollBar,
-		strings.Repeat(mid, max(0, borderHeight-scroll-scrollBarHeight)),
-		bot)
+	return renderHeader(m.viewport.Width, title, subtitle)
 }
 
 func (m model) getContentSeparator(s string) string {
-	s = " " + s + " "
-
-	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
-	fill := contentWidth - 10
-	leftFill := int(math.Floor(float64(fill) / 2.0))
-	rightFill := contentWidth - gloss.Width(s) - leftFill - 2
-
-	separator := "╾" + strings.Repeat("─", leftFill) + s + strings.Repeat("─", rightFill) + "╼"
-	return color.MiddleGray.Foreground(separator) + "\n"
+	return renderSeparator(m.viewport, s)
 }
 
 func (m model) viewContent() string {
+	switch m.mode {
+	case modeHunks:
+		return m.viewHunks()
+	case modeCommit:
+		return m.viewCommit()
+	case modeConflict:
+		return m.viewConflict()
+	case modeReview:
+		return m.viewReview()
+	}
+	var prompt string
+	switch m.prompt {
+	case promptAbort:
+		prompt = "\n" + color.Danger.Foreground("Abort the "+m.head.op.Kind.String()+"?") + " " + m.promptKeys.choices() + "\n"
+	case promptGlob:
+		prompt = "\nMark files matching: " + m.input.View() + "\n"
+	}
+	if m.clean {
+		return "\nnothing to commit, working tree clean\n" + prompt
+	}
+
 	contentWidth := m.viewport.Width - m.viewport.Style.GetHorizontalPadding()
 
 	var out string
-	seenCategories := make(map[category]struct{})
+	rows := m.rows()
+	current := m.cursor(rows)
 
-	for i, v := range m.files {
-		_, ok := seenCategories[v.category]
-		if !ok {
-			seenCategories[v.category] = struct{}{}
-			out += m.getContentSeparator(string(v.category))
+	for n, r := range rows {
+		if n == 0 || rows[n-1].category != r.category {
+			out += m.getContentSeparator(string(r.category))
 		}
 
-		var line string
-		text := v.text(contentWidth)
+		// folders in the tree are indented on the side they are shown on, so the
+		// files on the right mirror those on the left
+		indent := strings.Repeat("  ", r.depth)
+		width := contentWidth - len(indent)
+
+		var (
+			line     string
+			text     string
+			position gloss.Position
+			selected bool
+		)
+		if r.folder() {
+			text, position = m.folderText(r, width)
+			selected = !slices.ContainsFunc(r.files, func(i int) bool {
+				return !m.files[i].marked && !m.inRange(i)
+			})
+		} else {
+			v := m.files[r.file]
+			// the matches are positions in the path, of which only the name is shown
+			offset := utf8.RuneCountInString(v.path) - utf8.RuneCountInString(r.name)
+			var positions []int
+			for _, p := range m.matches[r.file] {
+				if p >= offset {
+					positions = append(positions, p-offset)
+				}
+			}
+			text, position = v.text(r.name, width, positions), v.position()
+			selected = v.marked || m.inRange(r.file)
+		}
 
-		cursor := color.Magenta.Foreground(" ◈ ")
-		switch v.position() {
+		// the cursor and the selection share the space beside the file
+		var cursor string
+		switch {
+		case n == current && selected:
+			cursor = color.Cursor.Foreground(" ◈") + color.Selection.Foreground("●")
+		case n == current:
+			cursor = color.Cursor.Foreground(" ◈ ")
+		case selected:
+			cursor = color.Selection.Foreground(" ● ")
+		}
+		switch position {
 		case gloss.Left:
-			if i == m.selected {
-				text += cursor
-			}
-			line = text
+			text += cursor
+			line = indent + text
 		case gloss.Right:
-			if i == m.selected {
-				text = cursor + text
-			}
-			line = strings.Repeat(" ", contentWidth-gloss.Width(text)) + text
+			text = cursor + text + indent
+			line = strings.Repeat(" ", max(0, contentWidth-gloss.Width(text))) + text
 		}
 
 		out += line + "\n"
 	}
+	if len(rows) == 0 {
+		out += "\nno files match the filter\n"
+	}
 
-	return out
+	return out + prompt
 }
 
 func (m model) viewFooter() string {
-	helpText := color.MiddleGray.Foreground(m.help.View(m.keys))
-	footerContent := headerStyle.Render(helpText)
+	var keyMap help.KeyMap = m.keys
+	switch m.mode {
+	case modeHunks:
+		keyMap = m.hunkKeys
+	case modeCommit:
+		keyMap = m.commitKeys
+	case modeConflict:
+		keyMap = m.conflictKeys
+	case modeReview:
+		keyMap = m.reviewKeys
+	}
+	return renderFooter(m.viewport.Width, m.help, keyMap, m.message)
+}
+
+// prepare builds the status view of the repository that state, as read once at
+// startup, describes.
+func prepare(repo git.Backend, state git.RepoState) (*model, error) {
+	files, _, err := collect(repo)
+	if err != nil {
+		return nil, err
+	}
 
-	footerFill := m.viewport.Width - gloss.Width(footerContent)
-	leftFill := int(math.Floor(float64(footerFill) / 2.0))
-	rightFill := m.viewport.Width - gloss.Width(footerContent) - leftFill
+	filter := textinput.New()
+	filter.Prompt = "/"
+	model := &model{
+		frame:        newFrame(),
+		clean:        len(files) == 0,
+		keys:         keys,
+		hunkKeys:     hunkKeys,
+		commitKeys:   commitKeys,
+		conflictKeys: conflictKeys,
+		reviewKeys:   reviewKeys,
+		confirm:      confirmPolicy(settings.Confirm),
+		head:         newHead(state),
+		repo:         repo,
+		files:        files,
+		anchor:       -1,
+		collapsed:    map[string]bool{},
+		filter:       filter,
+		rootdir:      state.Dir,
+	}
 
-	return gloss.JoinHorizontal(gloss.Center,
-		strings.Repeat("─", max(1, leftFill)),
-		footerContent,
-		strings.Repeat("─", max(1, rightFill)))
+	for i, v := range model.files {
+		if v.category == Conflicts || v.category == Unstaged {
+			model.selected = i
+			break
+		}
+	}
+	model.enableKeys()
+
+	return model, nil
 }
 
-func prepare(state git.RepoState) *model {
-	lines := git.Status()
-	if len(lines) == 0 {
-		fmt.Println("nothing to commit, working tree clean")
-		os.Exit(0)
+// collect reads the worktree status and returns one sorted entry per category
+// that each path appears in.
+func collect(repo git.Backend) ([]file, git.BranchInfo, error) {
+	// counting commits against the upstream can be slow on large histories, so
+	// that is left to AheadBehind, which runs in the background
+	status, err := repo.Status(git.StatusOptions{NoAheadBehind: true})
+	if err != nil {
+		return nil, git.BranchInfo{}, err
 	}
 
+	lines := status.Files
 	files := make([]file, 0, len(lines))
 
 	for _, v := range lines {
-		staged := git.StatusCode(v.Staged)
-		tracked := git.StatusCode(v.Tracked)
+		staged := v.Staged
+		tracked := v.Tracked
+		if v.Unmerged() {
+			files = append(files, file{
+				category: Conflicts,
+				path:     v.Path,
+				status:   git.UpdatedButUnmerged,
+				conflict: v.Conflict(),
+				pending:  map[action]bool{},
+			})
+			continue
+		}
 		if staged == git.Untracked && tracked == git.Untracked {
 			files = append(files, file{
 				category: Untracked,
@@ -471,6 +1183,7 @@ func prepare(state git.RepoState) *model {
 				path:     v.Path,
 				status:   staged,
 				staged:   true,
+				extra:    v.OrigPath,
 				pending:  map[action]bool{},
 			})
 		}
@@ -484,54 +1197,294 @@ func prepare(state git.RepoState) *model {
 		}
 	}
 
-	headname := state.Branch
-	if headname == "" {
-		headname = state.Ref
-	}
-
-	model := &model{
-		clean: len(files) == 0,
-		keys:  keys,
-		help:  help.New(),
-		head: head{
-			name:     headname,
-			ref:      state.Ref,
-			isbranch: state.Branch != "",
-		},
-		files:   files,
-		rootdir: state.Dir,
-	}
-
-	if model.head.isbranch {
-		model.ahead, model.behind = git.AheadBehind(model.head.name)
-	}
-
-	emptyStyle := gloss.NewStyle()
-	model.help.Styles.FullKey = emptyStyle
-	model.help.Styles.FullDesc = emptyStyle
-	model.help.Styles.ShortKey = emptyStyle
-	model.help.Styles.ShortDesc = emptyStyle
-	model.help.ShowAll = true
-
-	slices.SortFunc(model.files, func(a file, b file) int {
+	slices.SortFunc(files, func(a file, b file) int {
 		if a.category != b.category {
 			return strings.Compare(string(a.category), string(b.category))
 		}
 		return strings.Compare(a.path, b.path)
 	})
 
-	for i, v := range model.files {
-		if v.category == Unstaged {
-			model.selected = i
-			break
+	return files, status.Branch, nil
+}
+
+// reload re-reads the worktree status and rebuilds the file list in place.
+// Pending actions and the selection are carried over to files that are still
+// present, and the cursor stays on the same file or the nearest remaining one.
+func (m *model) reload() error {
+	var current, anchor fileKey
+	if len(m.files) > 0 {
+		current = fileKey{m.files[m.selected].category, m.files[m.selected].path}
+	}
+	if m.anchor >= 0 {
+		anchor = fileKey{m.files[m.anchor].category, m.files[m.anchor].path}
+	}
+	pending := make(map[fileKey]map[action]bool, len(m.files))
+	marked := make(map[fileKey]bool)
+	for _, v := range m.files {
+		pending[fileKey{v.category, v.path}] = v.pending
+		marked[fileKey{v.category, v.path}] = v.marked
+	}
+
+	files, branch, err := collect(m.repo)
+	if err != nil {
+		return err
+	}
+	index := make(map[fileKey]int, len(files))
+	m.anchor = -1
+	for i, v := range files {
+		k := fileKey{v.category, v.path}
+		if p, ok := pending[k]; ok {
+			files[i].pending = p
+		}
+		files[i].marked = marked[k]
+		index[k] = i
+		if k == anchor {
+			m.anchor = i
+		}
+	}
+	selected, ok := index[current]
+	if !ok {
+		selected = m.nearest(index)
+	}
+
+	op, err := m.repo.Operation()
+	if err != nil {
+		return err
+	}
+
+	// HEAD may have moved, e.g. by a commit or a checkout in another terminal
+	if branch.OID != "" {
+		m.head = head{
+			name:     cmp.Or(branch.Head, branch.OID[:7]),
+			ref:      branch.OID,
+			isbranch: branch.Head != "",
 		}
 	}
+	m.head.op = op
+
+	m.files = files
+	m.selected = selected
+	m.clean = len(files) == 0
+	m.enableKeys()
+	m.applyFilter()
+	m.viewport.SetContent(m.viewContent())
+	m.preview.loaded = false
+	return nil
+}
+
+// nearest returns the new index of the file closest to the cursor in the list
+// before a reload, looking below the cursor first, for when the file under it
+// is gone.
+func (m model) nearest(index map[fileKey]int) int {
+	for d := 1; d < len(m.files); d++ {
+		for _, i := range []int{m.selected + d, m.selected - d} {
+			if i < 0 || i >= len(m.files) {
+				continue
+			}
+			if j, ok := index[fileKey{m.files[i].category, m.files[i].path}]; ok {
+				return j
+			}
+		}
+	}
+	return min(m.selected, max(0, len(index)-1))
+}
+
+// refresh brings the view up to date after the worktree or the index changed
+// outside of got.
+func (m *model) refresh() {
+	if err := m.reload(); err != nil {
+		m.fail(err)
+		return
+	}
+	switch m.mode {
+	case modeHunks:
+		m.refreshHunks()
+	case modeConflict:
+		m.refreshConflict()
+	}
+}
+
+// enableKeys shows the help for the conflict actions only while there are
+// conflicts, and for the operation actions only while one is in progress.
+func (m *model) enableKeys() {
+	has := slices.ContainsFunc(m.files, func(f file) bool {
+		return f.category == Conflicts
+	})
+	m.keys.Ours.SetEnabled(has)
+	m.keys.Theirs.SetEnabled(has)
+	// git refuses to stash while there are conflicts
+	m.keys.Stash.SetEnabled(!has)
+
+	kind := m.head.op.Kind
+	m.keys.Continue.SetEnabled(kind.CanContinue())
+	m.keys.Skip.SetEnabled(kind.CanSkip())
+	m.keys.Abort.SetEnabled(kind != git.OpNone)
+}
+
+// continuedMsg is sent when git is done continuing the operation.
+type continuedMsg struct {
+	err error
+}
+
+// terminal runs a call to the backend as a command that the view hands the
+// terminal over to while it runs.
+type terminal func() error
+
+func (t terminal) Run() error        { return t() }
+func (terminal) SetStdin(io.Reader)  {}
+func (terminal) SetStdout(io.Writer) {}
+func (terminal) SetStderr(io.Writer) {}
+
+// continuePending applies the pending actions, which are usually what the
+// operation waits for, and then continues it.
+func (m model) continuePending() (tea.Model, tea.Cmd) {
+	if err := m.process(m.files); err != nil {
+		m.fail(err)
+		return m, nil
+	}
+	return m, m.continueOp()
+}
+
+// continueOp continues the operation in progress on the terminal, so that git
+// can open the editor for the commit messages it wants edited, e.g. of a merge
+// or a reworded commit.
+func (m model) continueOp() tea.Cmd {
+	repo, kind := m.repo, m.head.op.Kind
+	return tea.Exec(terminal(func() error {
+		return repo.Continue(kind)
+	}), func(err error) tea.Msg {
+		return continuedMsg{err: err}
+	})
+}
+
+// operate continues, skips or aborts the operation in progress. Pending actions
+// are dropped once it moved on, as they were made for the files of the step
+// before.
+func (m *model) operate(fn func(git.OpKind) error) {
+	if err := fn(m.head.op.Kind); err != nil {
+		m.fail(err)
+	} else {
+		for _, v := range m.files {
+			clear(v.pending)
+		}
+	}
+	// a failed step may still have moved on, e.g. to the next conflict
+	if err := m.reload(); err != nil {
+		m.fail(err)
+	}
+}
+
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p, pattern, cmd := m.answer(msg)
+	switch p {
+	case promptAbort:
+		m.operate(m.repo.Abort)
+	case promptGlob:
+		if err := m.selectGlob(pattern); err != nil {
+			m.fail(err)
+		}
+	}
+	return m, cmd
+}
+
+// shiftLeft moves a file one step towards the left of the list: it drops a
+// pending resolution, stash or stage, or else marks the file to be restored or
+// unstaged. It reports whether anything changed.
+func (m *model) shiftLeft(f file) bool {
+	switch {
+	case f.category == Conflicts:
+		if _, ok := f.resolution(); !ok {
+			return false
+		}
+		clear(f.pending)
+	case f.pending[stash]:
+		m.markStash(f.path, false)
+	case f.pending[stage]:
+		f.pending[stage] = false
+	case f.category != Untracked && !f.pending[restore] &&
+		(f.pending[unstage] || (!f.staged && !f.pending[restore])):
+		f.pending[restore] = true
+	case f.staged && !f.pending[unstage]:
+		f.pending[unstage] = true
+	default:
+		return false
+	}
+	return true
+}
+
+// shiftRight moves a file one step towards the right of the list: it drops a
+// pending stash, restore or unstage, or else marks the file to be staged or
+// resolved. It reports whether anything changed.
+func (m *model) shiftRight(f file) bool {
+	switch {
+	case f.category == Conflicts:
+		if _, ok := f.resolution(); ok {
+			return false
+		}
+		f.pending[resolve] = true
+	case f.pending[stash]:
+		m.markStash(f.path, false)
+	case f.pending[restore]:
+		f.pending[restore] = false
+	case f.staged && f.pending[unstage]:
+		f.pending[unstage] = false
+	case !f.staged && !f.pending[stage]:
+		f.pending[stage] = true
+	default:
+		return false
+	}
+	return true
+}
+
+// resolveWith marks a conflict to be resolved with one side, or clears the
+// mark, replacing any other resolution.
+func resolveWith(f file, a action, on bool) {
+	clear(f.pending)
+	f.pending[a] = on
+}
+
+// markStash marks a path to be stashed on submit, or clears the mark. Stashing
+// takes the whole path with it, so the mark replaces any other pending action
+// and applies to every category the path appears in.
+func (m *model) markStash(path string, on bool) {
+	for _, v := range m.files {
+		if v.path != path {
+			continue
+		}
+		clear(v.pending)
+		v.pending[stash] = on
+	}
+}
+
+// pendingSummary describes the pending actions, e.g. "stage 2, restore 1", or
+// returns an empty string if there are none.
+func (m model) pendingSummary() string {
+	counts := make(map[action]int)
+	for _, v := range m.files {
+		for a, on := range v.pending {
+			if on {
+				counts[a]++
+			}
+		}
+	}
+
+	parts := make([]string, 0, len(counts))
+	for _, a := range []action{stage, unstage, restore, stash, ours, theirs, resolve} {
+		if counts[a] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", a, counts[a]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
 
-	return model
+// fail shows an error in the message bar in place of the key help, until the
+// next key press.
+func (m *model) fail(err error) {
+	m.message = err.Error()
 }
 
-func flags(args []string) []string {
-	flagset := flag.NewFlagSet("got status", flag.ExitOnError)
+func flags(command string, args []string) []string {
+	flagset := flag.NewFlagSet("got "+command, flag.ExitOnError)
 	if err := flagset.Parse(args); err != nil {
 		flagset.Usage()
 	}
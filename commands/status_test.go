@@ -0,0 +1,901 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cv4x/got/git"
+)
+
+func unstaged(path string) git.FileStatus {
+	return git.FileStatus{Path: path, Staged: git.Unmodified, Tracked: git.Modified}
+}
+
+func staged(path string) git.FileStatus {
+	return git.FileStatus{Path: path, Staged: git.Modified, Tracked: git.Unmodified}
+}
+
+func conflicted(path, code string) git.FileStatus {
+	return git.FileStatus{
+		Path:     path,
+		Staged:   git.StatusCode(code[0]),
+		Tracked:  git.StatusCode(code[1]),
+		StageIDs: [3]string{"1111111", "2222222", "3333333"},
+	}
+}
+
+func untracked(path string) git.FileStatus {
+	return git.FileStatus{Path: path, Staged: git.Untracked, Tracked: git.Untracked}
+}
+
+func newTestModel(t *testing.T, files ...git.FileStatus) (model, *git.Fake) {
+	t.Helper()
+
+	fake := &git.Fake{
+		State: git.RepoState{Ref: "0123456789abcdef", Branch: "main", Dir: "/repo"},
+		Files: files,
+	}
+	m, err := prepare(fake, fake.State)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	return update(t, *m, tea.WindowSizeMsg{Width: 100, Height: 30}), fake
+}
+
+func update(t *testing.T, m model, msgs ...tea.Msg) model {
+	t.Helper()
+
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func press(keys ...string) []tea.Msg {
+	msgs := make([]tea.Msg, 0, len(keys))
+	for _, k := range keys {
+		switch k {
+		case "enter":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyEnter})
+		case "esc":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyEsc})
+		case "tab":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyTab})
+		case "home":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyHome})
+		case "end":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyEnd})
+		case "ctrl+r":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyCtrlR})
+		case "ctrl+s":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyCtrlS})
+		case " ":
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+		default:
+			r, alt := strings.CutPrefix(k, "alt+")
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(r), Alt: alt})
+		}
+	}
+	return msgs
+}
+
+func find(t *testing.T, m model, c category, path string) file {
+	t.Helper()
+
+	i := slices.IndexFunc(m.files, func(f file) bool {
+		return f.category == c && f.path == path
+	})
+	if i < 0 {
+		t.Fatalf("no %s file %q", c, path)
+	}
+	return m.files[i]
+}
+
+func TestPendingActions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		files    []git.FileStatus
+		keys     []string
+		category category
+		path     string
+		want     []action
+	}{
+		{
+			name:     "stage unstaged file",
+			files:    []git.FileStatus{unstaged("a.go"), unstaged("b.go")},
+			keys:     []string{"l"},
+			category: Unstaged,
+			path:     "a.go",
+			want:     []action{stage},
+		},
+		{
+			name:     "restore unstaged file",
+			files:    []git.FileStatus{unstaged("a.go"), unstaged("b.go")},
+			keys:     []string{"h"},
+			category: Unstaged,
+			path:     "a.go",
+			want:     []action{restore},
+		},
+		{
+			name:     "stage cancels restore",
+			files:    []git.FileStatus{unstaged("a.go"), unstaged("b.go")},
+			keys:     []string{"h", "k", "l"},
+			category: Unstaged,
+			path:     "a.go",
+		},
+		{
+			name:     "restore cancels stage",
+			files:    []git.FileStatus{unstaged("a.go"), unstaged("b.go")},
+			keys:     []string{"l", "k", "h"},
+			category: Unstaged,
+			path:     "a.go",
+		},
+		{
+			name:     "unstage staged file",
+			files:    []git.FileStatus{staged("a.go")},
+			keys:     []string{"h"},
+			category: Staged,
+			path:     "a.go",
+			want:     []action{unstage},
+		},
+		{
+			name:     "unstage and restore staged file",
+			files:    []git.FileStatus{staged("a.go")},
+			keys:     []string{"h", "h"},
+			category: Staged,
+			path:     "a.go",
+			want:     []action{unstage, restore},
+		},
+		{
+			name:     "untracked file cannot be restored",
+			files:    []git.FileStatus{untracked("a.go")},
+			keys:     []string{"h"},
+			category: Untracked,
+			path:     "a.go",
+		},
+		{
+			name:     "navigate to second file",
+			files:    []git.FileStatus{unstaged("a.go"), unstaged("b.go")},
+			keys:     []string{"j", "l"},
+			category: Unstaged,
+			path:     "b.go",
+			want:     []action{stage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, _ := newTestModel(t, tt.files...)
+			m = update(t, m, press(tt.keys...)...)
+
+			var got []action
+			for _, a := range []action{stage, unstage, restore} {
+				if find(t, m, tt.category, tt.path).pending[a] {
+					got = append(got, a)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("pending actions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, staged("a.go"), unstaged("b.go"), unstaged("c.go"))
+	m = update(t, m, press("l", "h", "enter", "enter")...)
+
+	// discarding changes has to be confirmed after the review
+	_, cmd := m.Update(press("y")[0])
+	if cmd == nil {
+		t.Fatal("submit returned no command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Errorf("submit did not quit")
+	}
+
+	if want := []string{"/repo/b.go"}; !slices.Equal(fake.Added, want) {
+		t.Errorf("added %v, want %v", fake.Added, want)
+	}
+	if want := []string{"/repo/c.go"}; !slices.Equal(fake.Restored, want) {
+		t.Errorf("restored %v, want %v", fake.Restored, want)
+	}
+	if len(fake.Unstaged) > 0 {
+		t.Errorf("unstaged %v, want nothing", fake.Unstaged)
+	}
+}
+
+func TestSubmitFailure(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"))
+	m = update(t, m, press("l")...)
+	fake.Err = errors.New("index.lock exists")
+
+	next, cmd := m.Update(press("enter")[0])
+	if cmd != nil {
+		if _, ok := cmd().(tea.QuitMsg); ok {
+			t.Fatal("submit quit despite failure")
+		}
+	}
+
+	if view := next.(model).View(); !strings.Contains(view, "index.lock exists") {
+		t.Errorf("view does not show the error:\n%s", view)
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"), unstaged("b.go"), unstaged("c.go"))
+	m = update(t, m, press("w")...)
+	if m.message != "nothing to apply" {
+		t.Errorf("message = %q, want it to say there is nothing to apply", m.message)
+	}
+
+	m = update(t, m, press("l", "l", "k")...)
+	next, cmd := m.Update(press("w")[0])
+	if cmd != nil {
+		if _, ok := cmd().(tea.QuitMsg); ok {
+			t.Fatal("apply quit")
+		}
+	}
+	m = next.(model)
+
+	if want := []string{"/repo/a.go", "/repo/b.go"}; !slices.Equal(fake.Added, want) {
+		t.Errorf("added %v, want %v", fake.Added, want)
+	}
+	if f := find(t, m, Staged, "b.go"); f.pending[unstage] || f.pending[stage] {
+		t.Errorf("b.go still has pending actions: %v", f.pending)
+	}
+	// the cursor was on b.go, which left the unstaged files
+	if f := m.files[m.selected]; f.category != Unstaged || f.path != "c.go" {
+		t.Errorf("cursor on %s %s, want the unstaged c.go", f.category, f.path)
+	}
+	m = update(t, m, press("u")...)
+	if m.message != "nothing to undo" {
+		t.Errorf("undo after apply: message = %q", m.message)
+	}
+}
+
+const testDiff = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,2 +1,2 @@
+ package a
+-var x = 1
++var x = 2
+`
+
+func TestHunks(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"))
+	fake.Diffs = map[string]git.FileDiff{"a.go": git.ParseDiff([]byte(testDiff))[0]}
+
+	m = update(t, m, press("tab", "l")...)
+	if m.mode != modeHunks {
+		t.Fatalf("mode = %v, want the hunks of a.go", m.mode)
+	}
+	if len(fake.Patches) != 1 {
+		t.Fatalf("applied %d patches, want 1", len(fake.Patches))
+	}
+	if p := fake.Patches[0]; !p.Cached || p.Reverse || !strings.Contains(p.Patch, "+var x = 2") {
+		t.Errorf("staging applied %+v, want the hunk to the index", p)
+	}
+
+	// only the added line, taken out of the worktree
+	update(t, m, press("tab", "j", "h")...)
+	if len(fake.Patches) != 2 {
+		t.Fatalf("applied %d patches, want 2", len(fake.Patches))
+	}
+	if p := fake.Patches[1]; p.Cached || !p.Reverse || !strings.Contains(p.Patch, "@@ -1 +1,2 @@\n package a\n+var x = 2\n") {
+		t.Errorf("discarding applied %+v, want the line reversed in the worktree", p)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, staged("a.go"), unstaged("b.go"))
+	fake.Message = "Fix the thing\n"
+
+	m = update(t, m, press("c", "alt+a")...)
+	if got := m.commit.editor.Value(); got != "Fix the thing" {
+		t.Errorf("amending starts from %q, want the last message", got)
+	}
+	_, cmd := m.Update(press("ctrl+s")[0])
+	if cmd == nil {
+		t.Fatal("commit returned no command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Errorf("commit did not quit")
+	}
+	want := []git.CommitOptions{{Message: "Fix the thing", Amend: true}}
+	if !reflect.DeepEqual(fake.Commits, want) {
+		t.Errorf("commits = %+v, want %+v", fake.Commits, want)
+	}
+}
+
+func TestCommitReview(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, staged("a.go"), unstaged("b.go"))
+	m = update(t, m, press("h", "c", "F", "i", "x", "ctrl+s")...)
+	if m.mode != modeReview || len(fake.Commits) > 0 || len(fake.Restored) > 0 {
+		t.Fatalf("committed without reviewing the pending restore")
+	}
+
+	// going back returns to the message
+	m = update(t, m, press("q")...)
+	if m.mode != modeCommit || m.commit.editor.Value() != "Fix" {
+		t.Fatalf("going back did not return to the message")
+	}
+
+	m = update(t, m, press("ctrl+s", "enter")...)
+	_, cmd := m.Update(press("y")[0])
+	if cmd == nil {
+		t.Fatal("confirming returned no command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Errorf("commit did not quit")
+	}
+	if want := []string{"/repo/b.go"}; !slices.Equal(fake.Restored, want) {
+		t.Errorf("restored %v, want %v", fake.Restored, want)
+	}
+	if want := []git.CommitOptions{{Message: "Fix"}}; !reflect.DeepEqual(fake.Commits, want) {
+		t.Errorf("commits = %+v, want %+v", fake.Commits, want)
+	}
+}
+
+func TestReview(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, staged("a.go"), unstaged("b.go"), unstaged("c.go"))
+	fake.Lines = map[string]git.LineCount{"b.go": {Added: 3, Deleted: 1}, "c.go": {Binary: true}}
+	m.confirm = confirmAlways
+	m = update(t, m, press("l", "enter")...)
+
+	if m.mode != modeReview {
+		t.Fatalf("submit did not show the review")
+	}
+	view := m.View()
+	for _, want := range []string{"Stage (1)", "M b.go", "+3 -1"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("review does not contain %q:\n%s", want, view)
+		}
+	}
+
+	// going back keeps the pending actions
+	m = update(t, m, press("q", "h", "enter")...)
+	if m.mode != modeReview || !find(t, m, Unstaged, "b.go").pending[stage] {
+		t.Fatalf("going back lost the pending actions")
+	}
+	if view := m.View(); !strings.Contains(view, "Restore (1)") || !strings.Contains(view, "binary") {
+		t.Errorf("review does not list the restored file:\n%s", view)
+	}
+
+	// discarding changes needs a second confirmation, which can be declined
+	m = update(t, m, press("enter")...)
+	if !strings.Contains(m.View(), "Discard the changes to 1 file?") {
+		t.Errorf("review did not ask to confirm:\n%s", m.View())
+	}
+	m = update(t, m, press("n")...)
+	if m.mode != modeReview || m.review.asking || len(fake.Restored) > 0 {
+		t.Fatalf("declining did not go back to the review")
+	}
+
+	m = update(t, m, press("enter")...)
+	if _, cmd := m.Update(press("y")[0]); cmd == nil {
+		t.Fatal("confirming returned no command")
+	}
+	if want := []string{"/repo/c.go"}; !slices.Equal(fake.Restored, want) {
+		t.Errorf("restored %v, want %v", fake.Restored, want)
+	}
+}
+
+func TestView(t *testing.T) {
+	t.Parallel()
+
+	m, _ := newTestModel(t, staged("a.go"), unstaged("b.go"), unstaged("c.go"), untracked("d.go"))
+	m = update(t, m, press("l")...)
+	view := m.View()
+
+	for _, want := range []string{"On branch main (0123456)", "Staged", "Unstaged", "Untracked", "? d.go"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not contain %q:\n%s", want, view)
+		}
+	}
+
+	lines := strings.Split(view, "\n")
+	line := func(s string) string {
+		i := slices.IndexFunc(lines, func(l string) bool { return strings.Contains(l, s) })
+		if i < 0 {
+			t.Fatalf("view has no line containing %q:\n%s", s, view)
+		}
+		return lines[i]
+	}
+
+	// staged files and files pending to be staged are shown on the right
+	if l := line("M b.go"); strings.Index(l, "M b.go") < 40 {
+		t.Errorf("pending file is not right aligned: %q", l)
+	}
+	if l := line("M a.go"); strings.Index(l, "M a.go") < 40 {
+		t.Errorf("staged file is not right aligned: %q", l)
+	}
+	if l := line("M c.go"); strings.Index(l, "M c.go") > 10 || !strings.Contains(l, "◈") {
+		t.Errorf("selected file is not left aligned with the cursor: %q", l)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"), unstaged("cmd/b.go"), unstaged("cmd/c.go"), unstaged("lib/d.go"))
+	// stage a.go, then mark cmd/c.go with only the cmd files shown
+	m = update(t, m, press("l", "/", "cmd", "enter")...)
+
+	view := m.View()
+	for _, want := range []string{"/cmd 2/4", "cmd/b.go", "cmd/c.go"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not contain %q:\n%s", want, view)
+		}
+	}
+	for _, hidden := range []string{"a.go", "d.go"} {
+		if strings.Contains(view, hidden) {
+			t.Errorf("view contains %s, which does not match:\n%s", hidden, view)
+		}
+	}
+
+	m = update(t, m, press("n", "l")...)
+	if !find(t, m, Unstaged, "cmd/c.go").pending[stage] {
+		t.Errorf("cmd/c.go is not marked for staging")
+	}
+	if f := m.files[m.selected]; f.path != "cmd/b.go" {
+		t.Errorf("cursor on %s, want it to wrap around to cmd/b.go", f.path)
+	}
+
+	// the first esc clears the filter rather than quitting
+	next, cmd := m.Update(press("esc")[0])
+	m = next.(model)
+	if cmd != nil {
+		t.Errorf("esc with a filter returned a command")
+	}
+	if view := m.View(); !strings.Contains(view, "d.go") {
+		t.Errorf("cleared filter still hides files:\n%s", view)
+	}
+	if !find(t, m, Unstaged, "a.go").pending[stage] {
+		t.Errorf("pending action on a hidden file was lost")
+	}
+
+	m.Update(press("enter")[0])
+	if want := []string{"/repo/a.go", "/repo/cmd/c.go"}; !slices.Equal(fake.Added, want) {
+		t.Errorf("added %v, want %v", fake.Added, want)
+	}
+}
+
+func TestFilterNoMatch(t *testing.T) {
+	t.Parallel()
+
+	m, _ := newTestModel(t, unstaged("a.go"), unstaged("b.go"))
+	m = update(t, m, press("/", "zz", "enter")...)
+
+	if view := m.View(); !strings.Contains(view, "no files match the filter") {
+		t.Errorf("view does not say nothing matches:\n%s", view)
+	}
+	// keys must not act on hidden files
+	m = update(t, m, press("l", "j", "s")...)
+	for _, f := range m.files {
+		if f.pending[stage] || f.pending[stash] {
+			t.Errorf("%s got pending actions %v", f.path, f.pending)
+		}
+	}
+}
+
+func TestSelection(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("gen/a.pb.go"), unstaged("gen/b.pb.go"), unstaged("gen/c.pb.go"),
+		unstaged("main.go"), untracked("notes.txt"))
+
+	// stage a range, which leaves the cursor where the range ended
+	m = update(t, m, press("v", "j", "j", "l")...)
+	for _, path := range []string{"gen/a.pb.go", "gen/b.pb.go", "gen/c.pb.go"} {
+		if !find(t, m, Unstaged, path).pending[stage] {
+			t.Errorf("%s is not marked for staging", path)
+		}
+	}
+	if find(t, m, Unstaged, "main.go").pending[stage] {
+		t.Errorf("main.go outside of the range is marked for staging")
+	}
+	if m.selecting() {
+		t.Errorf("selection was not cleared after the action")
+	}
+	if f := m.files[m.selected]; f.path != "gen/c.pb.go" {
+		t.Errorf("cursor on %s, want gen/c.pb.go", f.path)
+	}
+
+	// a pattern without a slash matches file names in any directory
+	m = update(t, m, press("*", "*.pb.go", "enter")...)
+	if got := len(m.targets()); got != 3 {
+		t.Fatalf("glob selected %d files, want 3", got)
+	}
+	m = update(t, m, press("h")...)
+	for _, path := range []string{"gen/a.pb.go", "gen/b.pb.go", "gen/c.pb.go"} {
+		if f := find(t, m, Unstaged, path); f.pending[stage] || f.pending[restore] {
+			t.Errorf("%s still has pending actions %v", path, f.pending)
+		}
+	}
+
+	// mark the untracked file, then invert to get everything else
+	m = update(t, m, press("end", " ", "i")...)
+	if got := len(m.targets()); got != 4 {
+		t.Errorf("inverted selection has %d files, want 4", got)
+	}
+	if find(t, m, Untracked, "notes.txt").marked {
+		t.Errorf("inverting kept the mark on notes.txt")
+	}
+	if view := m.View(); !strings.Contains(view, "4 selected") {
+		t.Errorf("header does not count the selection:\n%s", view)
+	}
+
+	// the first esc drops the selection rather than quitting
+	next, cmd := m.Update(press("esc")[0])
+	m = next.(model)
+	if cmd != nil || m.selecting() {
+		t.Errorf("esc did not just clear the selection")
+	}
+
+	m = update(t, m, press("*", "*.rs", "enter")...)
+	if m.message == "" || m.selecting() {
+		t.Errorf("glob without matches selected %v, message %q", m.targets(), m.message)
+	}
+
+	// marking a whole category stashes it with one key
+	m = update(t, m, press("home", "a", "s")...)
+	m.Update(press("enter")[0])
+	want := []string{"/repo/gen/a.pb.go", "/repo/gen/b.pb.go", "/repo/gen/c.pb.go", "/repo/main.go"}
+	if !slices.Equal(fake.Stashed, want) {
+		t.Errorf("stashed %v, want %v", fake.Stashed, want)
+	}
+}
+
+func TestTree(t *testing.T) {
+	t.Parallel()
+
+	m, _ := newTestModel(t, unstaged("src/api/a.go"), unstaged("src/api/b.go"), unstaged("src/web/c.go"),
+		unstaged("docs/x/y/z.md"), unstaged("main.go"))
+	m = update(t, m, press("T")...)
+
+	var got []string
+	for _, r := range m.rows() {
+		got = append(got, strings.Repeat(" ", r.depth)+r.name)
+	}
+	want := []string{"docs/x/y/", " z.md", "main.go", "src/", " api/", "  a.go", "  b.go", " web/", "  c.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	if view := m.View(); !strings.Contains(view, "▾ src/ M3") {
+		t.Errorf("view does not count the files in src/:\n%s", view)
+	}
+
+	// collapsing moves the cursor from the file to its folder, which then
+	// stages all of it
+	m = update(t, m, press("z", "l")...)
+	if view := m.View(); !strings.Contains(view, "▸ docs/x/y/ M1") || strings.Contains(view, "z.md") {
+		t.Errorf("docs/x/y/ is not collapsed:\n%s", view)
+	}
+	if !find(t, m, Unstaged, "docs/x/y/z.md").pending[stage] {
+		t.Errorf("file in the collapsed folder is not marked for staging")
+	}
+
+	m = update(t, m, press("j", "j", "h")...)
+	for _, path := range []string{"src/api/a.go", "src/api/b.go", "src/web/c.go"} {
+		if !find(t, m, Unstaged, path).pending[restore] {
+			t.Errorf("%s is not marked for restoring", path)
+		}
+	}
+	if find(t, m, Unstaged, "main.go").pending[restore] {
+		t.Errorf("main.go outside of the folder is marked for restoring")
+	}
+
+	// back in the flat list, the cursor is on the first file of the folder
+	m = update(t, m, press("T")...)
+	if m.folder != "" || m.files[m.selected].path != "src/api/a.go" {
+		t.Errorf("cursor on %q %s, want src/api/a.go", m.folder, m.files[m.selected].path)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"), unstaged("b.go"))
+	m = update(t, m, press("l", "h", "u")...)
+
+	if f := find(t, m, Unstaged, "b.go"); f.pending[restore] || !find(t, m, Unstaged, "a.go").pending[stage] {
+		t.Errorf("undo did not take back the last change only")
+	}
+	m = update(t, m, press("u", "u")...)
+	if find(t, m, Unstaged, "a.go").pending[stage] {
+		t.Errorf("undo did not take back the first change")
+	}
+	if m.message != "nothing to undo" {
+		t.Errorf("message = %q, want it to say there is nothing to undo", m.message)
+	}
+
+	// moving the cursor leaves the history alone
+	m = update(t, m, press("j", "U", "ctrl+r")...)
+	if !find(t, m, Unstaged, "a.go").pending[stage] || !find(t, m, Unstaged, "b.go").pending[restore] {
+		t.Errorf("redo did not bring back both changes")
+	}
+
+	// restored content is saved before it is discarded
+	m = update(t, m, press("enter", "enter")...)
+	m.Update(press("y")[0])
+	if want := [][]string{{"/repo/b.go"}}; !reflect.DeepEqual(fake.Snapshots, want) {
+		t.Errorf("snapshots %v, want %v", fake.Snapshots, want)
+	}
+}
+
+func TestRefreshKeepsState(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("b.go"), unstaged("c.go"))
+	m = update(t, m, press("l")...)
+
+	fake.Files = append(fake.Files, unstaged("a.go"))
+	m = update(t, m, changedMsg{})
+
+	if len(m.files) != 3 {
+		t.Fatalf("got %d files after refresh, want 3", len(m.files))
+	}
+	if !find(t, m, Unstaged, "b.go").pending[stage] {
+		t.Errorf("pending action was lost")
+	}
+	if f := m.files[m.selected]; f.path != "c.go" {
+		t.Errorf("cursor on %s, want c.go", f.path)
+	}
+}
+
+func TestClean(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"))
+	fake.Files = nil
+	m = update(t, m, changedMsg{})
+
+	if !m.clean {
+		t.Fatal("model is not clean")
+	}
+	if view := m.View(); !strings.Contains(view, "working tree clean") {
+		t.Errorf("view does not say the tree is clean:\n%s", view)
+	}
+	// keys must not act on files that are gone
+	update(t, m, press("l", "h", "j")...)
+}
+
+func TestStash(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, staged("a.go"), unstaged("a.go"), unstaged("b.go"), untracked("c.go"))
+	// the cursor starts on the first unstaged file and wraps around to the
+	// staged one after marking c.go
+	m = update(t, m, press("s", "j", "s", "l")...)
+
+	if find(t, m, Unstaged, "a.go").pending[stash] || find(t, m, Staged, "a.go").pending[stash] {
+		t.Errorf("stash mark on a.go was not cleared")
+	}
+	if !find(t, m, Untracked, "c.go").pending[stash] {
+		t.Errorf("c.go is not marked for stashing")
+	}
+
+	m = update(t, m, press("s")...)
+	if !find(t, m, Staged, "a.go").pending[stash] {
+		t.Errorf("stash mark does not apply to every category of a.go")
+	}
+
+	if _, cmd := m.Update(press("enter")[0]); cmd == nil {
+		t.Fatal("submit returned no command")
+	}
+	if want := []string{"/repo/a.go", "/repo/c.go"}; !slices.Equal(fake.Stashed, want) {
+		t.Errorf("stashed %v, want %v", fake.Stashed, want)
+	}
+	if len(fake.Added) > 0 || len(fake.Restored) > 0 {
+		t.Errorf("stashed files were also added %v or restored %v", fake.Added, fake.Restored)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"), conflicted("b.go", "UU"), conflicted("c.go", "UD"), conflicted("d.go", "AA"))
+	if f := m.files[m.selected]; f.category != Conflicts || f.path != "b.go" {
+		t.Fatalf("cursor on %s %s, want the first conflict", f.category, f.path)
+	}
+	if f := find(t, m, Conflicts, "c.go"); f.conflict != git.DeletedByThem {
+		t.Errorf("c.go conflict = %v, want %v", f.conflict, git.DeletedByThem)
+	}
+	if view := m.View(); !strings.Contains(view, "UD c.go (deleted by them)") {
+		t.Errorf("view does not describe the conflict:\n%s", view)
+	}
+
+	// staging and stashing do not apply to conflicts
+	m = update(t, m, press("o", "t", "s", "l")...)
+	if !find(t, m, Conflicts, "b.go").pending[ours] {
+		t.Errorf("b.go is not marked to take ours")
+	}
+	if !find(t, m, Conflicts, "c.go").pending[theirs] {
+		t.Errorf("c.go is not marked to take theirs")
+	}
+	if p := find(t, m, Conflicts, "d.go").pending; p[stash] || !p[resolve] {
+		t.Errorf("d.go pending = %v, want resolve", p)
+	}
+
+	m = update(t, m, press("enter", "enter")...)
+	if _, cmd := m.Update(press("y")[0]); cmd == nil {
+		t.Fatal("submit returned no command")
+	}
+	if want := []string{"/repo/b.go"}; !slices.Equal(fake.Ours, want) {
+		t.Errorf("took ours for %v, want %v", fake.Ours, want)
+	}
+	if want := []string{"/repo/c.go"}; !slices.Equal(fake.Theirs, want) {
+		t.Errorf("took theirs for %v, want %v", fake.Theirs, want)
+	}
+	if want := []string{"/repo/d.go"}; !slices.Equal(fake.Resolved, want) {
+		t.Errorf("resolved %v, want %v", fake.Resolved, want)
+	}
+}
+
+func TestOperation(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, conflicted("b.go", "UU"))
+	fake.State.Operation = git.Operation{Kind: git.OpRebase, Head: "main", Onto: "origin/main", Step: 2, Total: 5}
+	m = update(t, m, changedMsg{})
+	if view := m.View(); !strings.Contains(view, "Rebasing main onto origin/main (2/5)") {
+		t.Errorf("view does not show the rebase:\n%s", view)
+	}
+
+	// an abort has to be confirmed
+	m = update(t, m, press("A", "n")...)
+	if len(fake.Ops) > 0 {
+		t.Fatalf("ran %v without confirmation", fake.Ops)
+	}
+
+	m = update(t, m, press("l")...)
+	next, cmd := m.Update(press("C")[0])
+	m = next.(model)
+	if want := []string{"/repo/b.go"}; !slices.Equal(fake.Resolved, want) {
+		t.Errorf("resolved %v before continuing, want %v", fake.Resolved, want)
+	}
+	// git continues on the terminal, which the program hands over to it
+	if cmd == nil || len(fake.Ops) > 0 {
+		t.Fatalf("continued without handing the terminal to git, ran %v", fake.Ops)
+	}
+	m = update(t, m, continuedMsg{err: fake.Continue(git.OpRebase)})
+	if want := []string{"rebase continue"}; !slices.Equal(fake.Ops, want) {
+		t.Errorf("ran %v, want %v", fake.Ops, want)
+	}
+	if m.head.op.Kind != git.OpNone {
+		t.Errorf("operation %v is still shown after it finished", m.head.op.Kind)
+	}
+}
+
+func TestContinueReview(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"))
+	fake.State.Operation = git.Operation{Kind: git.OpMerge, Commit: "topic"}
+	m = update(t, m, changedMsg{})
+
+	m = update(t, m, press("h", "C")...)
+	if m.mode != modeReview || len(fake.Restored) > 0 {
+		t.Fatalf("continued without reviewing the pending restore")
+	}
+
+	m = update(t, m, press("enter")...)
+	next, cmd := m.Update(press("y")[0])
+	if want := []string{"/repo/a.go"}; !slices.Equal(fake.Restored, want) {
+		t.Errorf("restored %v, want %v", fake.Restored, want)
+	}
+	if cmd == nil || next.(model).mode != modeFiles {
+		t.Fatalf("confirming did not continue")
+	}
+}
+
+func TestAbort(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"))
+	fake.State.Operation = git.Operation{Kind: git.OpMerge, Commit: "topic"}
+	m = update(t, m, changedMsg{})
+
+	m = update(t, m, press("S", "A")...)
+	if view := m.View(); !strings.Contains(view, "Abort the merge? y/n") {
+		t.Errorf("view does not ask to abort:\n%s", view)
+	}
+	update(t, m, press("y")...)
+	// a merge has no steps to skip
+	if want := []string{"merge abort"}; !slices.Equal(fake.Ops, want) {
+		t.Errorf("ran %v, want %v", fake.Ops, want)
+	}
+}
+
+func TestPromptKeys(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, unstaged("a.go"))
+	fake.State.Operation = git.Operation{Kind: git.OpMerge, Commit: "topic"}
+	m.promptKeys.Yes = key.NewBinding(key.WithKeys("o"))
+	m.promptKeys.No = key.NewBinding(key.WithKeys("x"))
+	m = update(t, m, changedMsg{})
+
+	m = update(t, m, press("A")...)
+	if view := m.View(); !strings.Contains(view, "Abort the merge? o/x") {
+		t.Errorf("view does not show the rebound answers:\n%s", view)
+	}
+	m = update(t, m, press("y", "n")...)
+	if len(fake.Ops) > 0 || m.prompt != promptAbort {
+		t.Fatalf("y or n answered the prompt, ran %v", fake.Ops)
+	}
+	update(t, m, press("o")...)
+	if want := []string{"merge abort"}; !slices.Equal(fake.Ops, want) {
+		t.Errorf("ran %v, want %v", fake.Ops, want)
+	}
+}
+
+func TestTooSmall(t *testing.T) {
+	t.Parallel()
+
+	m, _ := newTestModel(t, unstaged("a.go"))
+	m.keys.Quit = key.NewBinding(key.WithKeys("Q", "ctrl+q"))
+	m = update(t, m, tea.WindowSizeMsg{Width: 30, Height: 8})
+	if view := m.View(); !strings.Contains(view, "press Q/ctrl+q to exit") {
+		t.Errorf("view does not name the rebound quit keys:\n%s", view)
+	}
+}
+
+func TestAheadBehind(t *testing.T) {
+	t.Parallel()
+
+	m, fake := newTestModel(t, staged("a.go"), unstaged("b.go"))
+	fake.Branch = git.BranchInfo{OID: fake.State.Ref, Head: "main", Upstream: "origin/main"}
+	fake.Tracking = git.Tracking{Upstream: "origin/main", Ahead: 1, Behind: 2}
+	m = update(t, m, m.Init()())
+	if m.ahead != 1 || m.behind != 2 {
+		t.Fatalf("ahead, behind = %d, %d, want 1, 2", m.ahead, m.behind)
+	}
+
+	// a commit made in another terminal
+	if err := fake.Commit(git.CommitOptions{Message: "More"}); err != nil {
+		t.Fatal(err)
+	}
+	next, cmd := m.Update(changedMsg{})
+	if cmd == nil {
+		t.Fatal("moving HEAD did not count the commits again")
+	}
+	m = update(t, next.(model), cmd())
+	if m.ahead != 2 || m.behind != 2 {
+		t.Errorf("ahead, behind = %d, %d, want 2, 2", m.ahead, m.behind)
+	}
+	if view := m.View(); !strings.Contains(view, "2 ▲") {
+		t.Errorf("view does not show the new count:\n%s", view)
+	}
+
+	// nothing moved, nothing to count
+	if _, cmd := m.Update(changedMsg{}); cmd != nil {
+		t.Errorf("a change to the worktree counted the commits again")
+	}
+}
@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+// In the tree view, the files of each category are grouped by directory. A
+// folder can be collapsed, and the actions on it apply to all the files it
+// holds. The cursor is on a folder when model.folder names it, and on the file
+// at model.selected otherwise.
+
+// row is a line of the file list: a file, or a folder in the tree view.
+type row struct {
+	category category
+	// file is the index of the file, or -1 for a folder.
+	file int
+	// dir is the path of the folder, or of the folder the file is in, with a
+	// trailing slash.
+	dir string
+	// name is what the row adds to the path of its parent folder. A chain of
+	// folders that each hold a single subfolder is shown as one row.
+	name  string
+	depth int
+	// files are the indices of the files under a folder that pass the filter,
+	// whether it is collapsed or not.
+	files []int
+}
+
+func (r row) folder() bool {
+	return r.file < 0
+}
+
+// key identifies a folder across reloads.
+func (r row) key() string {
+	return string(r.category) + ":" + r.dir
+}
+
+// statusOrder is the order in which a folder counts its files by status.
+var statusOrder = []git.StatusCode{
+	git.UpdatedButUnmerged, git.Added, git.Modified, git.Renamed, git.Copied, git.Deleted, git.Untracked,
+}
+
+// rows lays out the files that pass the filter, as a flat list or as a tree.
+func (m model) rows() []row {
+	var (
+		rows  []row
+		group []int
+	)
+	for i, f := range m.files {
+		if !m.visible(i) {
+			continue
+		}
+		if !m.tree {
+			rows = append(rows, row{category: f.category, file: i, name: f.path})
+			continue
+		}
+		if len(group) > 0 && m.files[group[0]].category != f.category {
+			rows = m.appendTree(rows, group, "", 0)
+			group = nil
+		}
+		group = append(group, i)
+	}
+	if len(group) > 0 {
+		rows = m.appendTree(rows, group, "", 0)
+	}
+	return rows
+}
+
+// appendTree adds the rows for files, which all lie in the folder dir. As the
+// files are sorted by path, those in the same subfolder are next to each other.
+func (m model) appendTree(rows []row, files []int, dir string, depth int) []row {
+	for len(files) > 0 {
+		f := m.files[files[0]]
+		rest := strings.TrimPrefix(f.path, dir)
+		sub, _, nested := strings.Cut(rest, "/")
+		if !nested {
+			rows = append(rows, row{category: f.category, file: files[0], dir: dir, name: rest, depth: depth})
+			files = files[1:]
+			continue
+		}
+
+		prefix := dir + sub + "/"
+		n := 1
+		for n < len(files) && strings.HasPrefix(m.files[files[n]].path, prefix) {
+			n++
+		}
+		group := files[:n:n]
+		files = files[n:]
+
+		for {
+			next, _, ok := strings.Cut(strings.TrimPrefix(f.path, prefix), "/")
+			if !ok || slices.ContainsFunc(group, func(i int) bool {
+				return !strings.HasPrefix(m.files[i].path, prefix+next+"/")
+			}) {
+				break
+			}
+			prefix += next + "/"
+		}
+
+		folder := row{
+			category: f.category,
+			file:     -1,
+			dir:      prefix,
+			name:     strings.TrimPrefix(prefix, dir),
+			depth:    depth,
+			files:    group,
+		}
+		rows = append(rows, folder)
+		if !m.collapsed[folder.key()] {
+			rows = m.appendTree(rows, group, prefix, depth+1)
+		}
+	}
+	return rows
+}
+
+// cursor returns the index of the row under the cursor. A file in a collapsed
+// folder is stood in for by the folder.
+func (m model) cursor(rows []row) int {
+	if m.folder != "" {
+		for i, r := range rows {
+			if r.folder() && r.key() == m.folder {
+				return i
+			}
+		}
+	}
+	at := 0
+	for i, r := range rows {
+		if r.file == m.selected {
+			return i
+		}
+		if r.folder() && slices.Contains(r.files, m.selected) {
+			at = i
+		}
+	}
+	return at
+}
+
+// current returns the folder under the cursor, if it is on one.
+func (m model) current() (row, bool) {
+	if m.folder == "" {
+		return row{}, false
+	}
+	rows := m.rows()
+	if len(rows) == 0 {
+		return row{}, false
+	}
+	r := rows[m.cursor(rows)]
+	return r, r.folder()
+}
+
+// setRow puts the cursor on a row. On a folder, the first file in it stands in
+// as the selected file, e.g. for the preview.
+func (m *model) setRow(r row) {
+	if r.folder() {
+		m.folder = r.key()
+		m.selected = r.files[0]
+	} else {
+		m.folder = ""
+		m.selected = r.file
+	}
+}
+
+// move steps the cursor over the rows.
+func (m *model) move(delta int) {
+	rows := m.rows()
+	if len(rows) == 0 {
+		return
+	}
+	n := len(rows)
+	m.setRow(rows[((m.cursor(rows)+delta)%n+n)%n])
+	m.scroll()
+}
+
+// to puts the cursor on the row at index i, counting from the end if negative.
+func (m *model) to(i int) {
+	rows := m.rows()
+	if len(rows) == 0 {
+		return
+	}
+	if i < 0 {
+		i += len(rows)
+	}
+	m.setRow(rows[i])
+	m.scroll()
+}
+
+// settle puts the cursor back on a row after the rows changed, e.g. when the
+// folder it was on is gone, or the file it was on was collapsed.
+func (m *model) settle() {
+	rows := m.rows()
+	if len(rows) > 0 {
+		m.setRow(rows[m.cursor(rows)])
+	}
+}
+
+// scroll keeps the cursor around the middle of the viewport.
+func (m *model) scroll() {
+	rows := m.rows()
+	pos := m.cursor(rows)
+
+	mid := m.viewport.VisibleLineCount() / 2
+	max := m.viewport.TotalLineCount()
+	if pos < mid {
+		m.viewport.GotoTop()
+	} else if pos > max-mid {
+		m.viewport.GotoBottom()
+	}
+
+	percentpos := float64(pos) / float64(len(rows)-1)
+	if len(rows) < 2 {
+		percentpos = 0
+	}
+	scrollto := int(float64(m.viewport.TotalLineCount()) * percentpos)
+	m.viewport.SetYOffset(scrollto - mid)
+}
+
+// toggleTree switches between the flat list and the tree.
+func (m *model) toggleTree() {
+	m.tree = !m.tree
+	m.keys.Fold.SetEnabled(m.tree)
+	m.settle()
+	m.viewport.SetContent(m.viewContent())
+	m.scroll()
+}
+
+// fold collapses or expands the folder under the cursor. On a file, it
+// collapses the folder the file is in.
+func (m *model) fold() {
+	rows := m.rows()
+	if len(rows) == 0 {
+		return
+	}
+	r := rows[m.cursor(rows)]
+	if !r.folder() {
+		i := slices.IndexFunc(rows, func(p row) bool {
+			return p.folder() && p.category == r.category && p.dir == r.dir
+		})
+		if i < 0 {
+			return
+		}
+		r = rows[i]
+	}
+	m.collapsed[r.key()] = !m.collapsed[r.key()]
+	m.setRow(r)
+	m.viewport.SetContent(m.viewContent())
+	m.scroll()
+}
+
+// folderText renders a folder with the number of files under it by status. The
+// folder is on the right once all of its files are.
+func (m model) folderText(r row, maxWidth int) (string, gloss.Position) {
+	icon := "▾ "
+	if m.collapsed[r.key()] {
+		icon = "▸ "
+	}
+
+	count := make(map[git.StatusCode]int)
+	position := gloss.Right
+	for _, i := range r.files {
+		count[m.files[i].status]++
+		if m.files[i].position() == gloss.Left {
+			position = gloss.Left
+		}
+	}
+	var counts, plain []string
+	for _, code := range statusOrder {
+		if n := count[code]; n > 0 {
+			s := fmt.Sprintf("%c%d", code, n)
+			plain = append(plain, s)
+			counts = append(counts, color.ByStatus(s, code, r.category == Staged))
+		}
+	}
+
+	name := r.name
+	room := maxWidth - 8 - gloss.Width(icon) - gloss.Width(strings.Join(plain, " ")) - 1
+	if runes := []rune(name); len(runes) > room {
+		name = "…" + string(runes[max(0, len(runes)-room+1):])
+	}
+	return icon + name + " " + strings.Join(counts, " "), position
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+// Undo brings back what got saved before it last discarded changes in the
+// worktree or the index, or lists what it saved. Staged changes come back into
+// the index.
+func Undo(state git.RepoState, args []string) error {
+	flagset := flag.NewFlagSet("got undo", flag.ExitOnError)
+	list := flagset.Bool("list", false, "list the snapshots, most recent first, instead of restoring one")
+	force := flagset.Bool("f", false, "overwrite changes made to the files since the snapshot was taken")
+	flagset.Usage = func() {
+		fmt.Fprintln(flagset.Output(), "usage: got undo [-f] [<n>]\n       got undo -list")
+		flagset.PrintDefaults()
+	}
+	if err := flagset.Parse(args); err != nil {
+		flagset.Usage()
+	}
+
+	snapshots, err := git.Snapshots()
+	if err != nil {
+		return err
+	}
+	if *list {
+		for i, s := range snapshots {
+			fmt.Printf("%s %s %s\n", color.Ref.Foreground(strconv.Itoa(i)),
+				s.Message, color.Date.Foreground("("+relativeTime(s.Date)+")"))
+			for _, p := range s.Paths {
+				fmt.Println("\t" + p)
+			}
+		}
+		return nil
+	}
+
+	n := 0
+	if flagset.NArg() > 0 {
+		n, err = strconv.Atoi(flagset.Arg(0))
+		if err != nil || flagset.NArg() > 1 {
+			flagset.Usage()
+			return errors.New("the snapshot to restore is given by its number in got undo -list")
+		}
+	}
+	if len(snapshots) == 0 {
+		return errors.New("nothing to undo")
+	}
+	if n < 0 || n >= len(snapshots) {
+		return fmt.Errorf("there are only %d snapshots", len(snapshots))
+	}
+
+	s := snapshots[n]
+	if err := git.RestoreSnapshot(s, *force); err != nil {
+		return err
+	}
+	verb := "Restored"
+	if s.Staged {
+		verb = "Staged"
+	}
+	fmt.Printf("%s %s as saved %s\n\n", verb, strings.Join(s.Paths, ", "), relativeTime(s.Date))
+	return nil
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cv4x/got/git"
+)
+
+// newRepo creates a repository with a.txt committed in it and makes it the
+// working directory for the rest of the test, which therefore cannot run in
+// parallel.
+func newRepo(t *testing.T, content string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// keep the configuration of the machine out of the way
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "test")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "test@localhost")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	gitRun(t, "init", "-q", "-b", "main")
+	writeFile(t, "a.txt", content)
+	gitRun(t, "add", "a.txt")
+	gitRun(t, "commit", "-q", "-m", "initial")
+}
+
+func gitRun(t *testing.T, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wantFile fails the test unless path holds want.
+func wantFile(t *testing.T, path, want, after string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s after %s = %q, want %q", path, after, got, want)
+	}
+}
+
+// wantIndex fails the test unless the index holds want for path.
+func wantIndex(t *testing.T, path, want, after string) {
+	t.Helper()
+	got, err := exec.Command("git", "show", ":"+path).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s in the index after %s = %q, want %q", path, after, got, want)
+	}
+}
+
+// newRepoModel opens the status view on the repository in the working
+// directory.
+func newRepoModel(t *testing.T) model {
+	t.Helper()
+	repo := git.CLI{}
+	state, err := repo.CurrentRef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := prepare(repo, state)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	return update(t, *m, tea.WindowSizeMsg{Width: 100, Height: 30})
+}
+
+func undo(t *testing.T, args ...string) {
+	t.Helper()
+	if err := Undo(git.RepoState{}, args); err != nil {
+		t.Fatalf("got undo %s: %v", strings.Join(args, " "), err)
+	}
+}
+
+func TestUndoUnstageRestore(t *testing.T) {
+	newRepo(t, "one\n")
+	writeFile(t, "a.txt", "two\n")
+	gitRun(t, "add", "a.txt")
+	writeFile(t, "a.txt", "three\n")
+
+	// restore the unstaged change, which moves the cursor on to the staged one,
+	// and unstage that
+	m := newRepoModel(t)
+	m = update(t, m, press("h", "h", "enter", "enter")...)
+	m.Update(press("y")[0])
+	wantFile(t, "a.txt", "one\n", "unstaging and restoring")
+
+	// the worktree comes back first, then what was staged
+	undo(t)
+	wantFile(t, "a.txt", "three\n", "the first undo")
+	undo(t)
+	wantIndex(t, "a.txt", "two\n", "the second undo")
+	wantFile(t, "a.txt", "three\n", "the second undo")
+}
+
+func TestUndoUnstage(t *testing.T) {
+	newRepo(t, "one\n")
+	writeFile(t, "a.txt", "two\n")
+	gitRun(t, "add", "a.txt")
+	writeFile(t, "a.txt", "three\n")
+
+	// the unstaged change comes first, the staged one below it
+	m := newRepoModel(t)
+	m = update(t, m, press("j", "h", "enter")...)
+	wantIndex(t, "a.txt", "one\n", "unstaging")
+
+	undo(t)
+	wantIndex(t, "a.txt", "two\n", "undo")
+	wantFile(t, "a.txt", "three\n", "undo")
+}
+
+func TestUndoHunk(t *testing.T) {
+	newRepo(t, "1\n2\n3\n")
+	writeFile(t, "a.txt", "1\ntwo\n3\n")
+
+	m := newRepoModel(t)
+	update(t, m, press("tab", "h")...)
+	wantFile(t, "a.txt", "1\n2\n3\n", "discarding the hunk")
+
+	undo(t)
+	wantFile(t, "a.txt", "1\ntwo\n3\n", "undo")
+}
+
+func TestUndoUnstageHunk(t *testing.T) {
+	newRepo(t, "1\n2\n3\n")
+	writeFile(t, "a.txt", "1\ntwo\n3\n")
+	gitRun(t, "add", "a.txt")
+	writeFile(t, "a.txt", "1\ntwo\nthree\n")
+
+	m := newRepoModel(t)
+	update(t, m, press("j", "tab", "h")...)
+	wantIndex(t, "a.txt", "1\n2\n3\n", "unstaging the hunk")
+
+	undo(t)
+	wantIndex(t, "a.txt", "1\ntwo\n3\n", "undo")
+	wantFile(t, "a.txt", "1\ntwo\nthree\n", "undo")
+}
+
+func TestUndoConflict(t *testing.T) {
+	newRepo(t, "base\n")
+	gitRun(t, "checkout", "-q", "-b", "topic")
+	writeFile(t, "a.txt", "theirs\n")
+	gitRun(t, "commit", "-q", "-am", "theirs")
+	gitRun(t, "checkout", "-q", "main")
+	writeFile(t, "a.txt", "ours\n")
+	gitRun(t, "commit", "-q", "-am", "ours")
+	if err := exec.Command("git", "merge", "-q", "topic").Run(); err == nil {
+		t.Fatal("merge did not conflict")
+	}
+	conflicted, err := os.ReadFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newRepoModel(t)
+	m = update(t, m, press("tab", "o")...)
+	if m.mode != modeFiles {
+		t.Fatalf("mode = %v, want the file list once the conflict is resolved", m.mode)
+	}
+	wantFile(t, "a.txt", "ours\n", "taking ours")
+
+	// the file is still unmerged, which counts as changed since the snapshot
+	undo(t, "-f")
+	wantFile(t, "a.txt", string(conflicted), "undo")
+}
@@ -0,0 +1,400 @@
+// Package config loads the settings of got. They are read from, in increasing
+// order of precedence:
+//
+//   - the user's config file, $XDG_CONFIG_HOME/got/config or else
+//     ~/.config/got/config
+//   - the [got] section of the repository's .git/config
+//   - environment variables, e.g. GOT_WIDTH for got.width
+//   - overrides given on the command line, as with got -c width=100
+//
+// Both files are in git config format, with the settings in the [got] section.
+// Whether to color the output, and the colors of the default theme for the
+// status codes, come from git's own color.ui and color.status settings.
+// Key bindings are set per view and action, and themes are defined by the
+// colors they change in another, e.g.
+//
+//	[got "keys.status"]
+//		right = l, right
+//	[got "themes.mine"]
+//		base = gruvbox
+//		cursor = #ff8700
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+// Config holds the settings of got.
+type Config struct {
+	// Width caps the width of the views, or is 0 to let them fill the terminal.
+	Width int
+	// Command is run when got is called without one.
+	Command string
+	// Confirm says when the status view shows the pending actions for review
+	// before applying them: always, destructive or never.
+	Confirm string
+	// Keymap names the preset the key bindings start from: vim, emacs or
+	// arrows.
+	Keymap string
+	// Keys holds the bindings set by got.keys.<view>.<action>, by
+	// "<view>.<action>". They are checked against the views by the commands.
+	Keys map[string]Binding
+	// Theme names the theme, one that got comes with or one of Themes.
+	Theme string
+	// Themes are the themes defined by got.themes.<name>.<role>, by name.
+	Themes map[string]CustomTheme
+	// Colors is the palette of the theme.
+	Colors color.Theme
+	// Color says whether to color the output, as color.status or color.ui
+	// do for git.
+	Color color.Mode
+}
+
+// CustomTheme is a theme defined in the configuration. It changes the colors
+// of some roles in the theme it is based on, by default the one that got comes
+// with of the same name, or else the default theme.
+type CustomTheme struct {
+	Base   string
+	Colors color.Theme
+}
+
+// Binding is the keys set for an action. No keys leave the action unbound.
+type Binding struct {
+	Keys []string
+	// Origin says where the binding was set, for error messages.
+	Origin string
+}
+
+// Default returns the settings used where nothing is configured.
+func Default() Config {
+	return Config{
+		Width:   80,
+		Command: "status",
+		Confirm: "destructive",
+		Keymap:  "vim",
+		Theme:   "default",
+		Colors:  color.Themes["default"],
+		Color:   color.Auto,
+	}
+}
+
+// setting is a key of the [got] section.
+type setting struct {
+	name string
+	help string
+	set  func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{
+		name: "command",
+		help: "command run when none is given: status, stash, branch or log",
+		set: func(c *Config, value string) error {
+			return oneOf(&c.Command, value, "status", "stash", "branch", "log")
+		},
+	},
+	{
+		name: "confirm",
+		help: "when to review pending actions: always, destructive or never",
+		set: func(c *Config, value string) error {
+			return oneOf(&c.Confirm, value, "always", "destructive", "never")
+		},
+	},
+	{
+		name: "keymap",
+		help: "preset key bindings: vim, emacs or arrows",
+		set: func(c *Config, value string) error {
+			return oneOf(&c.Keymap, value, "vim", "emacs", "arrows")
+		},
+	},
+	{
+		name: "theme",
+		help: "colors of the views: " + strings.Join(color.ThemeNames(), ", ") + ", or a theme of your own",
+		set: func(c *Config, value string) error {
+			c.Theme = value
+			return nil
+		},
+	},
+	{
+		name: "width",
+		help: "maximum width of the views in columns, or 0 for no limit",
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", value)
+			}
+			if n != 0 && n < 40 {
+				return fmt.Errorf("%d is too narrow, the least is 40", n)
+			}
+			c.Width = n
+			return nil
+		},
+	},
+}
+
+func oneOf(field *string, value string, allowed ...string) error {
+	if !slices.Contains(allowed, value) {
+		return fmt.Errorf("%q must be %s or %s", value,
+			strings.Join(allowed[:len(allowed)-1], ", "), allowed[len(allowed)-1])
+	}
+	*field = value
+	return nil
+}
+
+// source is a place settings are read from, with the entries found there.
+type source struct {
+	name    string
+	entries []git.ConfigEntry
+	// env is set for the environment, whose entries are named by variable.
+	env bool
+}
+
+// Load reads the settings from all sources. Overrides are "name=value" pairs,
+// the name with or without the "got." prefix. Any setting that is unknown or
+// has an invalid value is an error, which names the place it was read from.
+func Load(overrides ...string) (Config, error) {
+	path, err := File()
+	if err != nil {
+		return Config{}, err
+	}
+	user, err := git.ConfigEntries(path, "got")
+	if err != nil {
+		return Config{}, err
+	}
+	repo, err := git.ConfigEntries("", "got")
+	if err != nil {
+		return Config{}, err
+	}
+	colors, err := git.ConfigAll("color")
+	if err != nil {
+		return Config{}, err
+	}
+	return load(colors, []source{
+		{name: path, entries: user},
+		{name: ".git/config", entries: repo},
+		environment(os.Environ()),
+		commandLine(overrides),
+	})
+}
+
+// load reads the settings from the sources, and the color settings from the
+// entries of git's color section.
+func load(gitColors []git.ConfigEntry, sources []source) (Config, error) {
+	c := Default()
+	var (
+		errs  []error
+		theme = "got.theme"
+	)
+	mode, fromGit, err := color.FromGit(gitColors)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		c.Color = mode
+	}
+	for _, src := range sources {
+		for _, e := range src.entries {
+			key := e.Key
+			if src.env {
+				key = env(strings.TrimPrefix(key, "got."))
+			}
+			origin := key + " in " + src.name
+			if err := apply(&c, e, origin); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", origin, err))
+			}
+			if e.Key == "got.theme" {
+				theme = origin
+			}
+		}
+	}
+	// the theme may be defined after it is chosen
+	colors, err := c.palette(fromGit)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", theme, err))
+	}
+	c.Colors = colors
+	return c, errors.Join(errs...)
+}
+
+// palette returns the colors of the chosen theme. Those set by git's
+// color.status.<slot> apply to the default theme and the themes based on it.
+func (c Config) palette(fromGit color.Theme) (color.Theme, error) {
+	builtin := func(name string) color.Theme {
+		if name == "default" {
+			return merge(color.Themes[name], fromGit)
+		}
+		return color.Themes[name]
+	}
+	custom, ok := c.Themes[c.Theme]
+	if !ok {
+		if _, ok := color.Themes[c.Theme]; ok {
+			return builtin(c.Theme), nil
+		}
+		names := color.ThemeNames()
+		for name := range c.Themes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return builtin("default"), fmt.Errorf("there is no theme named %q, only %s", c.Theme, strings.Join(names, ", "))
+	}
+
+	base := custom.Base
+	if base == "" {
+		base = "default"
+		if _, ok := color.Themes[c.Theme]; ok {
+			base = c.Theme
+		}
+	}
+	return merge(builtin(base), custom.Colors), nil
+}
+
+// merge returns a copy of a theme with the styles of another in place of its
+// own.
+func merge(t, changes color.Theme) color.Theme {
+	merged := make(color.Theme, len(t))
+	for r, style := range t {
+		merged[r] = style
+	}
+	for r, style := range changes {
+		merged[r] = style
+	}
+	return merged
+}
+
+func apply(c *Config, e git.ConfigEntry, origin string) error {
+	name := strings.TrimPrefix(e.Key, "got.")
+	if action, ok := strings.CutPrefix(name, "keys."); ok {
+		return bind(c, action, e.Value, origin)
+	}
+	if role, ok := strings.CutPrefix(name, "themes."); ok {
+		return paint(c, role, e.Value)
+	}
+	i := slices.IndexFunc(settings, func(s setting) bool { return s.name == name })
+	if i < 0 {
+		return errors.New("got has no such setting, see got -h")
+	}
+	return settings[i].set(c, e.Value)
+}
+
+// bind sets the keys for an action, given as a comma separated list. "none"
+// unbinds the action.
+func bind(c *Config, action, value, origin string) error {
+	view, name, ok := strings.Cut(strings.ToLower(action), ".")
+	if !ok || view == "" || name == "" {
+		return errors.New("key bindings are set as got.keys.<view>.<action>")
+	}
+	var keys []string
+	for _, k := range strings.Split(value, ",") {
+		if k = strings.TrimSpace(k); k != "" && k != "none" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 && strings.TrimSpace(value) != "none" {
+		return errors.New(`no keys given, "none" unbinds the action`)
+	}
+	if c.Keys == nil {
+		c.Keys = make(map[string]Binding)
+	}
+	c.Keys[view+"."+name] = Binding{Keys: keys, Origin: origin}
+	return nil
+}
+
+// paint sets the color of a role in a custom theme, or the theme it is based
+// on.
+func paint(c *Config, role, value string) error {
+	i := strings.LastIndex(role, ".")
+	if i <= 0 {
+		return errors.New("themes are set as got.themes.<theme>.<role>")
+	}
+	name, role := role[:i], role[i+1:]
+	t := c.Themes[name]
+	if t.Colors == nil {
+		t.Colors = make(color.Theme)
+	}
+
+	if role == "base" {
+		if _, ok := color.Themes[value]; !ok {
+			return fmt.Errorf("a theme can be based on %s, not %q", strings.Join(color.ThemeNames(), ", "), value)
+		}
+		t.Base = value
+	} else {
+		if !slices.Contains(color.Roles, color.Role(role)) {
+			roles := make([]string, len(color.Roles))
+			for i, r := range color.Roles {
+				roles[i] = string(r)
+			}
+			return fmt.Errorf("%q is not a part of the views, which are base, %s", role, strings.Join(roles, ", "))
+		}
+		style, err := color.ParseStyle(value)
+		if err != nil {
+			return err
+		}
+		t.Colors[color.Role(role)] = style
+	}
+
+	if c.Themes == nil {
+		c.Themes = make(map[string]CustomTheme)
+	}
+	c.Themes[name] = t
+	return nil
+}
+
+// environment picks out the variables that set a setting, e.g. GOT_WIDTH.
+func environment(environ []string) source {
+	src := source{name: "the environment", env: true}
+	for _, s := range settings {
+		prefix := env(s.name) + "="
+		for _, kv := range environ {
+			if value, ok := strings.CutPrefix(kv, prefix); ok {
+				src.entries = append(src.entries, git.ConfigEntry{Key: "got." + s.name, Value: value})
+			}
+		}
+	}
+	return src
+}
+
+// commandLine turns overrides into entries.
+func commandLine(overrides []string) source {
+	src := source{name: "-c"}
+	for _, o := range overrides {
+		name, value, _ := strings.Cut(o, "=")
+		name = strings.ToLower(strings.TrimPrefix(name, "got."))
+		src.entries = append(src.entries, git.ConfigEntry{Key: "got." + name, Value: value})
+	}
+	return src
+}
+
+func env(name string) string {
+	return "GOT_" + strings.ToUpper(name)
+}
+
+// File returns the path of the user's config file, which need not exist.
+func File() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "got", "config"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "got", "config"), nil
+}
+
+// Usage describes the settings for the help of got.
+func Usage() string {
+	var b strings.Builder
+	for _, s := range settings {
+		fmt.Fprintf(&b, "\tgot.%-10s %-14s %s\n", s.name, env(s.name), s.help)
+	}
+	fmt.Fprintf(&b, "\tgot.keys.<view>.<action>      keys for an action, e.g. got.keys.status.right = l, right\n")
+	fmt.Fprintf(&b, "\tgot.themes.<theme>.<role>     style of a part of the views in a theme, e.g. got.themes.mine.cursor = bold #ff8700\n")
+	return b.String()
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	gloss "github.com/charmbracelet/lipgloss"
+	"github.com/cv4x/got/color"
+	"github.com/cv4x/got/git"
+)
+
+func entry(key, value string) git.ConfigEntry {
+	return git.ConfigEntry{Key: key, Value: value}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	c, err := load(nil, []source{
+		{name: "user", entries: []git.ConfigEntry{entry("got.width", "100"), entry("got.command", "log")}},
+		{name: "repo", entries: []git.ConfigEntry{entry("got.width", "0"), entry("got.keys.Status.right", "l, right")}},
+		environment([]string{"HOME=/home/me", "GOT_CONFIRM=never"}),
+		commandLine([]string{"got.command=branch", "keymap=emacs", "keys.log.copy=none"}),
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := Config{Width: 0, Command: "branch", Confirm: "never", Keymap: "emacs", Keys: map[string]Binding{
+		"status.right": {Keys: []string{"l", "right"}, Origin: "got.keys.Status.right in repo"},
+		"log.copy":     {Origin: "got.keys.log.copy in -c"},
+	}, Theme: "default", Colors: color.Themes["default"], Color: color.Auto}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("config = %+v, want %+v", c, want)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	t.Parallel()
+
+	c, err := load(nil, []source{
+		{name: "user", entries: []git.ConfigEntry{
+			entry("got.theme", "mine"),
+			entry("got.themes.mine.cursor", "#ff8700"),
+			entry("got.themes.mine.base", "gruvbox"),
+			entry("got.themes.gruvbox.match", "none"),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := c.Colors[color.Cursor].GetForeground(); got != gloss.Color("#ff8700") {
+		t.Errorf("cursor = %v, want the color set for it", got)
+	}
+	if got, want := c.Colors[color.Selection].GetForeground(), color.Themes["gruvbox"][color.Selection].GetForeground(); got != want {
+		t.Errorf("selection = %v, want %v from the base theme", got, want)
+	}
+	// the changes to a theme got comes with apply once it is chosen
+	if got := c.Colors[color.Match].GetForeground(); got == (gloss.NoColor{}) {
+		t.Errorf("match of gruvbox changed the theme based on it")
+	}
+	c, _ = load(nil, []source{{name: "env", entries: []git.ConfigEntry{
+		entry("got.themes.gruvbox.match", "none"), entry("got.theme", "gruvbox"),
+	}}})
+	if got := c.Colors[color.Match].GetForeground(); got != (gloss.NoColor{}) {
+		t.Errorf("match = %v, want no color", got)
+	}
+	if got := color.Themes["gruvbox"][color.Match].GetForeground(); got == (gloss.NoColor{}) {
+		t.Errorf("changing a theme changed the one got comes with")
+	}
+}
+
+func TestGitColors(t *testing.T) {
+	t.Parallel()
+
+	gitColors := []git.ConfigEntry{
+		entry("color.ui", "never"),
+		entry("color.status", "always"),
+		entry("color.status.added", "bold 2"),
+		entry("color.diff.old", "red"),
+	}
+	c, err := load(gitColors, nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Color != color.Always {
+		t.Errorf("color = %s, want always from color.status", c.Color)
+	}
+	if s := c.Colors[color.StagedModified]; !s.GetBold() || s.GetForeground() != gloss.Color("2") {
+		t.Errorf("staged-modified = %v, want bold 2 from color.status.added", s)
+	}
+	if s := color.Themes["default"][color.StagedModified]; s.GetBold() {
+		t.Errorf("git's colors changed the default theme")
+	}
+
+	// other themes keep their own colors, but themes based on the default
+	// take git's
+	c, _ = load(gitColors, []source{{name: "user", entries: []git.ConfigEntry{
+		entry("got.theme", "solarized"),
+	}}})
+	if s := c.Colors[color.StagedModified]; s.GetBold() {
+		t.Errorf("git's colors changed solarized")
+	}
+	c, _ = load(gitColors, []source{{name: "user", entries: []git.ConfigEntry{
+		entry("got.theme", "mine"),
+		entry("got.themes.mine.cursor", "blue"),
+	}}})
+	if s := c.Colors[color.StagedAdded]; !s.GetBold() {
+		t.Errorf("a theme based on the default does not take git's colors")
+	}
+
+	_, err = load([]git.ConfigEntry{entry("color.status.changed", "reddish")}, nil)
+	if want := `color.status.changed in the git config: "reddish" is not a color or attribute`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := load(nil, []source{
+		{name: "user", entries: []git.ConfigEntry{entry("got.widht", "100"), entry("got.width", "20")}},
+		environment([]string{"GOT_CONFIRM=sometimes"}),
+		commandLine([]string{"width=wide", "keys.up=k", "keys.status.up=", "theme=nope", "themes.x.cursor=reddish", "themes.x.border=1", "themes.x.base=x"}),
+	})
+	if err == nil {
+		t.Fatal("load accepted invalid settings")
+	}
+	for _, want := range []string{
+		"got.widht in user: got has no such setting",
+		"got.width in user: 20 is too narrow",
+		`GOT_CONFIRM in the environment: "sometimes" must be always, destructive or never`,
+		`got.width in -c: "wide" is not a number`,
+		"got.keys.up in -c: key bindings are set as got.keys.<view>.<action>",
+		`got.keys.status.up in -c: no keys given`,
+		`got.theme in -c: there is no theme named "nope", only colorblind-safe, default, gruvbox, high-contrast, solarized`,
+		`got.themes.x.cursor in -c: "reddish" is not a color or attribute in "reddish"`,
+		`got.themes.x.border in -c: "border" is not a part of the views`,
+		`got.themes.x.base in -c: a theme can be based on colorblind-safe, default, gruvbox, high-contrast, solarized, not "x"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if path, err := File(); err != nil || path != "/xdg/got/config" {
+		t.Errorf("File() = %q, %v, want /xdg/got/config", path, err)
+	}
+
+	// a relative path is invalid per the spec, and ignored
+	t.Setenv("XDG_CONFIG_HOME", "xdg")
+	t.Setenv("HOME", "/home/me")
+	if path, err := File(); err != nil || path != "/home/me/.config/got/config" {
+		t.Errorf("File() = %q, %v, want /home/me/.config/got/config", path, err)
+	}
+}
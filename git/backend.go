@@ -0,0 +1,119 @@
+package git
+
+// Backend provides the repository operations the status view is built on, so
+// that it can run against something other than the git executable.
+type Backend interface {
+	CurrentRef() (RepoState, error)
+	GitDir() (string, error)
+	IgnoredDirs(root string) ([]string, error)
+	Status(opts StatusOptions) (StatusInfo, error)
+	AheadBehind(branch string) (Tracking, error)
+	NumStat(staged bool) (map[string]LineCount, error)
+	Diff(path string, staged bool) (FileDiff, error)
+	Apply(patch []byte, cached, reverse bool) error
+	Add(paths ...string) error
+	Unstage(paths ...string) error
+	Restore(paths ...string) error
+	StashPush(message string, paths ...string) error
+	Snapshot(message string, paths ...string) error
+	SnapshotStaged(message string, paths ...string) error
+	Take(path string, c Conflict, side Side) error
+	MarkResolved(paths ...string) error
+	Operation() (Operation, error)
+	Continue(k OpKind) error
+	Skip(k OpKind) error
+	Abort(k OpKind) error
+	Commit(opts CommitOptions) error
+	LastCommitMessage() (string, error)
+}
+
+// CLI is the Backend that runs the git executable.
+type CLI struct{}
+
+func (CLI) CurrentRef() (RepoState, error) {
+	return CurrentRef()
+}
+
+func (CLI) GitDir() (string, error) {
+	return GitDir()
+}
+
+func (CLI) IgnoredDirs(root string) ([]string, error) {
+	return IgnoredDirs(root)
+}
+
+func (CLI) Status(opts StatusOptions) (StatusInfo, error) {
+	return Status(opts)
+}
+
+func (CLI) AheadBehind(branch string) (Tracking, error) {
+	return AheadBehind(branch)
+}
+
+func (CLI) NumStat(staged bool) (map[string]LineCount, error) {
+	return NumStat(staged)
+}
+
+func (CLI) Diff(path string, staged bool) (FileDiff, error) {
+	return Diff(path, staged)
+}
+
+func (CLI) Apply(patch []byte, cached, reverse bool) error {
+	return Apply(patch, cached, reverse)
+}
+
+func (CLI) Add(paths ...string) error {
+	return Add(paths...)
+}
+
+func (CLI) Unstage(paths ...string) error {
+	return Unstage(paths...)
+}
+
+func (CLI) Restore(paths ...string) error {
+	return Restore(paths...)
+}
+
+func (CLI) StashPush(message string, paths ...string) error {
+	return StashPush(message, paths...)
+}
+
+func (CLI) Snapshot(message string, paths ...string) error {
+	return SaveSnapshot(message, paths...)
+}
+
+func (CLI) SnapshotStaged(message string, paths ...string) error {
+	return SaveStagedSnapshot(message, paths...)
+}
+
+func (CLI) Take(path string, c Conflict, side Side) error {
+	return Take(path, c, side)
+}
+
+func (CLI) MarkResolved(paths ...string) error {
+	return MarkResolved(paths...)
+}
+
+func (CLI) Operation() (Operation, error) {
+	return CurrentOperation()
+}
+
+func (CLI) Continue(k OpKind) error {
+	return Continue(k)
+}
+
+func (CLI) Skip(k OpKind) error {
+	return Skip(k)
+}
+
+func (CLI) Abort(k OpKind) error {
+	return Abort(k)
+}
+
+func (CLI) Commit(opts CommitOptions) error {
+	return Commit(opts)
+}
+
+func (CLI) LastCommitMessage() (string, error) {
+	return LastCommitMessage()
+}
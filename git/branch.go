@@ -0,0 +1,144 @@
+package git
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotMerged is returned when deleting a branch whose commits are not merged
+// into its upstream or HEAD, which would lose them.
+var ErrNotMerged = errors.New("branch is not fully merged")
+
+type Branch struct {
+	// Name is the short name, e.g. "main" or "origin/main" for a
+	// remote-tracking branch.
+	Name    string
+	Remote  bool
+	Current bool
+	OID     string
+	// Tracking is only filled in for local branches with an upstream.
+	Tracking Tracking
+	Author   string
+	Date     time.Time
+	Subject  string
+}
+
+// Branches lists the local branches followed by the remote-tracking ones, each
+// sorted by name.
+func Branches() ([]Branch, error) {
+	format := strings.Join([]string{
+		"%(refname)",
+		"%(symref)",
+		"%(HEAD)",
+		"%(objectname)",
+		"%(upstream:short)",
+		"%(upstream:track,nobracket)",
+		"%(authorname)",
+		"%(committerdate:unix)",
+		"%(contents:subject)",
+	}, "%1f")
+
+	stdout, err := execGit("for-each-ref", "--format="+format, "refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+	return parseBranches(stdout), nil
+}
+
+func parseBranches(b []byte) []Branch {
+	var branches []Branch
+	for _, l := range strings.Split(string(b), "\n") {
+		fields := strings.Split(l, "\x1f")
+		if len(fields) < 9 {
+			continue
+		}
+		// skip symbolic refs such as origin/HEAD
+		if fields[1] != "" {
+			continue
+		}
+
+		branch := Branch{
+			Current: fields[2] == "*",
+			OID:     fields[3],
+			Author:  fields[6],
+			Subject: fields[8],
+		}
+		if name, ok := strings.CutPrefix(fields[0], "refs/heads/"); ok {
+			branch.Name = name
+		} else {
+			branch.Name = strings.TrimPrefix(fields[0], "refs/remotes/")
+			branch.Remote = true
+		}
+		if fields[4] != "" {
+			branch.Tracking = parseTrack(fields[4], fields[5])
+		}
+		if secs, err := strconv.ParseInt(fields[7], 10, 64); err == nil {
+			branch.Date = time.Unix(secs, 0)
+		}
+		branches = append(branches, branch)
+	}
+	return branches
+}
+
+// parseTrack reads the upstream state as for-each-ref prints it, e.g. "ahead 1,
+// behind 2" or "gone".
+func parseTrack(upstream, track string) Tracking {
+	tracking := Tracking{Upstream: upstream}
+	for _, part := range strings.Split(track, ", ") {
+		word, count, _ := strings.Cut(part, " ")
+		switch word {
+		case "gone":
+			tracking.Gone = true
+		case "ahead":
+			tracking.Ahead, _ = strconv.Atoi(count)
+		case "behind":
+			tracking.Behind, _ = strconv.Atoi(count)
+		}
+	}
+	return tracking
+}
+
+// Checkout switches to a branch. Switching to a remote-tracking branch creates
+// a local branch of the same name that tracks it.
+func Checkout(b Branch) error {
+	if b.Remote {
+		_, err := execGit("switch", "--track", b.Name)
+		return err
+	}
+	_, err := execGit("switch", b.Name)
+	return err
+}
+
+// CreateBranch creates a branch pointing at start, without switching to it.
+func CreateBranch(name, start string) error {
+	_, err := execGit("branch", "--no-track", name, start)
+	return err
+}
+
+func RenameBranch(name, newName string) error {
+	_, err := execGit("branch", "--move", name, newName)
+	return err
+}
+
+// DeleteBranch deletes a local branch. Unless force is set, it refuses to
+// delete a branch that is not merged and returns ErrNotMerged.
+func DeleteBranch(name string, force bool) error {
+	args := []string{"branch", "--delete", name}
+	if force {
+		args = append(args, "--force")
+	}
+
+	_, err := execGitEnv(untranslated, args...)
+	var gitErr *Error
+	if errors.As(err, &gitErr) && strings.Contains(gitErr.Stderr, "not fully merged") {
+		return ErrNotMerged
+	}
+	return err
+}
+
+func SetUpstream(name, upstream string) error {
+	_, err := execGit("branch", "--set-upstream-to="+upstream, name)
+	return err
+}
@@ -0,0 +1,45 @@
+package git
+
+type CommitOptions struct {
+	Message  string
+	Amend    bool
+	Signoff  bool
+	NoVerify bool
+}
+
+// Commit records the index as a new commit. Output of failing hooks ends up in
+// the Stderr of the returned Error.
+func Commit(opts CommitOptions) error {
+	args := []string{"commit", "--file=-", "--cleanup=strip"}
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if opts.Signoff {
+		args = append(args, "--signoff")
+	}
+	if opts.NoVerify {
+		args = append(args, "--no-verify")
+	}
+
+	_, err := execGitInput([]byte(opts.Message), args...)
+	return err
+}
+
+// LastCommitMessage returns the full message of the commit HEAD points to.
+func LastCommitMessage() (string, error) {
+	stdout, err := execGit("log", "-1", "--format=%B")
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
+
+// Editor returns the editor git would launch, honoring $GIT_EDITOR,
+// core.editor, $VISUAL and $EDITOR in that order.
+func Editor() (string, error) {
+	stdout, err := execGit("var", "GIT_EDITOR")
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"errors"
+	"strings"
+)
+
+// ConfigEntry is a key and value from a git config file. Section and variable
+// names are lowercased, subsection names are kept as they are.
+type ConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// ConfigEntries returns the entries of a config file whose keys are in the
+// given section, in the order they appear, following includes. An empty file
+// means the config file of the repository.
+func ConfigEntries(file, section string) ([]ConfigEntry, error) {
+	if file == "" {
+		return configEntries(section, "--local")
+	}
+	return configEntries(section, "--file", file)
+}
+
+// ConfigAll returns the entries whose keys are in the given section from all
+// the config files git reads, system, global and local, in that order. A key
+// set in several of them comes up more than once, and the last one counts.
+func ConfigAll(section string) ([]ConfigEntry, error) {
+	return configEntries(section)
+}
+
+func configEntries(section string, scope ...string) ([]ConfigEntry, error) {
+	args := append([]string{"config", "--includes", "-z"}, scope...)
+	args = append(args, "--get-regexp", `^`+section+`\.`)
+	stdout, err := execGit(args...)
+	var gitErr *Error
+	if errors.As(err, &gitErr) && gitErr.ExitCode() == 1 {
+		// none of the keys is set
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigEntries(stdout), nil
+}
+
+func parseConfigEntries(b []byte) []ConfigEntry {
+	var entries []ConfigEntry
+	for _, record := range strings.Split(string(b), "\x00") {
+		if record == "" {
+			continue
+		}
+		// a key without a value, as in "[got] flag", has no newline
+		key, value, _ := strings.Cut(record, "\n")
+		entries = append(entries, ConfigEntry{Key: key, Value: value})
+	}
+	return entries
+}
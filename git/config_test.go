@@ -0,0 +1,21 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigEntries(t *testing.T) {
+	t.Parallel()
+
+	// the trailing NUL is trimmed along with git's output
+	got := parseConfigEntries([]byte("got.width\n100\x00got.flag\x00got.Keys.up\nk\nK"))
+	want := []ConfigEntry{
+		{Key: "got.width", Value: "100"},
+		{Key: "got.flag"},
+		{Key: "got.Keys.up", Value: "k\nK"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package git
+
+import (
+	"strings"
+)
+
+// Conflict is the kind of merge conflict of an unmerged path, named after the
+// two-letter code git shows for it.
+type Conflict byte
+
+const (
+	NoConflict    Conflict = iota
+	BothDeleted            // DD
+	AddedByUs              // AU
+	DeletedByThem          // UD
+	AddedByThem            // UA
+	DeletedByUs            // DU
+	BothAdded              // AA
+	BothModified           // UU
+)
+
+// Conflict decodes the status codes of an unmerged entry.
+func (f FileStatus) Conflict() Conflict {
+	if !f.Unmerged() {
+		return NoConflict
+	}
+	switch string([]byte{byte(f.Staged), byte(f.Tracked)}) {
+	case "DD":
+		return BothDeleted
+	case "AU":
+		return AddedByUs
+	case "UD":
+		return DeletedByThem
+	case "UA":
+		return AddedByThem
+	case "DU":
+		return DeletedByUs
+	case "AA":
+		return BothAdded
+	}
+	return BothModified
+}
+
+// Code returns the two-letter code of the conflict, e.g. "UU".
+func (c Conflict) Code() string {
+	return [...]string{"  ", "DD", "AU", "UD", "UA", "DU", "AA", "UU"}[c]
+}
+
+// String describes the conflict the way "git status" does.
+func (c Conflict) String() string {
+	return [...]string{"", "both deleted", "added by us", "deleted by them",
+		"added by them", "deleted by us", "both added", "both modified"}[c]
+}
+
+// Side selects one of the versions of a conflicted path.
+type Side byte
+
+const (
+	Ours Side = iota
+	Theirs
+)
+
+// has reports whether a side of the conflict still has the file.
+func (c Conflict) has(side Side) bool {
+	switch c {
+	case BothDeleted:
+		return false
+	case AddedByUs, DeletedByThem:
+		return side == Ours
+	case AddedByThem, DeletedByUs:
+		return side == Theirs
+	}
+	return true
+}
+
+// Take resolves a conflict with the version of one side, which deletes the
+// file if that side deleted it.
+func Take(path string, c Conflict, side Side) error {
+	if !c.has(side) {
+		_, err := execGit("rm", "--quiet", "--", path)
+		return err
+	}
+
+	flag := "--ours"
+	if side == Theirs {
+		flag = "--theirs"
+	}
+	if _, err := execGit("checkout", flag, "--", path); err != nil {
+		return err
+	}
+	return MarkResolved(path)
+}
+
+// MarkResolved records the worktree version of conflicted paths in the index,
+// or their removal if they no longer exist.
+func MarkResolved(paths ...string) error {
+	args := append([]string{"add", "--all", "--"}, paths...)
+	_, err := execGit(args...)
+	return err
+}
+
+// Chunk is a piece of a file with conflict markers: either plain lines, or a
+// conflict between the lines of two sides and, with the diff3 conflict style,
+// of their merge base. Lines keep their line endings.
+type Chunk struct {
+	Lines []string
+
+	Conflict    bool
+	Ours        []string
+	Base        []string
+	Theirs      []string
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+}
+
+// ParseMarkers splits a file into chunks at its conflict markers. Markers that
+// are not closed are kept as plain lines.
+func ParseMarkers(data string) []Chunk {
+	var (
+		chunks []Chunk
+		plain  []string
+		cur    *Chunk
+		// side is the part of the conflict being read: 0 ours, 1 base, 2 theirs
+		side int
+		raw  []string
+	)
+
+	for _, l := range strings.SplitAfter(data, "\n") {
+		if l == "" {
+			continue
+		}
+		marker, label := splitMarker(l)
+
+		switch {
+		case cur == nil && marker == "<<<<<<<":
+			cur = &Chunk{Conflict: true, OursLabel: label}
+			side = 0
+			raw = []string{l}
+			continue
+		case cur == nil:
+			plain = append(plain, l)
+			continue
+		}
+
+		raw = append(raw, l)
+		switch {
+		case marker == "|||||||" && side == 0:
+			cur.BaseLabel = label
+			side = 1
+		case marker == "=======" && side < 2:
+			side = 2
+		case marker == ">>>>>>>" && side == 2:
+			cur.TheirsLabel = label
+			if len(plain) > 0 {
+				chunks = append(chunks, Chunk{Lines: plain})
+				plain = nil
+			}
+			chunks = append(chunks, *cur)
+			cur = nil
+		case side == 0:
+			cur.Ours = append(cur.Ours, l)
+		case side == 1:
+			cur.Base = append(cur.Base, l)
+		default:
+			cur.Theirs = append(cur.Theirs, l)
+		}
+	}
+
+	if cur != nil {
+		plain = append(plain, raw...)
+	}
+	if len(plain) > 0 {
+		chunks = append(chunks, Chunk{Lines: plain})
+	}
+	return chunks
+}
+
+// splitMarker returns the conflict marker a line starts with, if any, and the
+// label that follows it.
+func splitMarker(l string) (string, string) {
+	l = strings.TrimRight(l, "\r\n")
+	for _, m := range []string{"<<<<<<<", "|||||||", "=======", ">>>>>>>"} {
+		rest, ok := strings.CutPrefix(l, m)
+		if !ok || (rest != "" && rest[0] != ' ') {
+			continue
+		}
+		return m, strings.TrimPrefix(rest, " ")
+	}
+	return "", ""
+}
+
+// JoinChunks puts a file back together from its chunks, with markers around
+// the conflicts that are left.
+func JoinChunks(chunks []Chunk) string {
+	var b strings.Builder
+	marker := func(m, label string) {
+		b.WriteString(strings.TrimRight(m+" "+label, " ") + "\n")
+	}
+	for _, c := range chunks {
+		if !c.Conflict {
+			b.WriteString(strings.Join(c.Lines, ""))
+			continue
+		}
+		marker("<<<<<<<", c.OursLabel)
+		b.WriteString(strings.Join(c.Ours, ""))
+		if c.BaseLabel != "" || len(c.Base) > 0 {
+			marker("|||||||", c.BaseLabel)
+			b.WriteString(strings.Join(c.Base, ""))
+		}
+		marker("=======", "")
+		b.WriteString(strings.Join(c.Theirs, ""))
+		marker(">>>>>>>", c.TheirsLabel)
+	}
+	return b.String()
+}
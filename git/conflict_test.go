@@ -0,0 +1,58 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkers(t *testing.T) {
+	t.Parallel()
+
+	data := "a\n" +
+		"<<<<<<< HEAD\n" +
+		"ours\n" +
+		"||||||| base\n" +
+		"base\n" +
+		"=======\n" +
+		"theirs 1\n" +
+		"theirs 2\n" +
+		">>>>>>> topic\n" +
+		"b\n" +
+		"<<<<<<< HEAD\n" +
+		"never closed\n"
+	chunks := ParseMarkers(data)
+
+	want := []Chunk{
+		{Lines: []string{"a\n"}},
+		{
+			Conflict:    true,
+			Ours:        []string{"ours\n"},
+			Base:        []string{"base\n"},
+			Theirs:      []string{"theirs 1\n", "theirs 2\n"},
+			OursLabel:   "HEAD",
+			BaseLabel:   "base",
+			TheirsLabel: "topic",
+		},
+		{Lines: []string{"b\n", "<<<<<<< HEAD\n", "never closed\n"}},
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("chunks = %+v, want %+v", chunks, want)
+	}
+	if got := JoinChunks(chunks); got != data {
+		t.Errorf("joined = %q, want %q", got, data)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	t.Parallel()
+
+	for code, want := range map[string]Conflict{
+		"DD": BothDeleted, "AU": AddedByUs, "UD": DeletedByThem, "UA": AddedByThem,
+		"DU": DeletedByUs, "AA": BothAdded, "UU": BothModified,
+	} {
+		info := ParseStatus([]byte("u " + code + " N... 100644 100644 100644 100644 1 2 3 f.txt\x00"))
+		if got := info.Files[0].Conflict(); got != want || got.Code() != code {
+			t.Errorf("%s decoded as %v (%s)", code, got, got.Code())
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type LineKind byte
+
+const (
+	Context  LineKind = ' '
+	Addition LineKind = '+'
+	Deletion LineKind = '-'
+)
+
+type Line struct {
+	Kind      LineKind
+	Text      string
+	NoNewline bool
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Section  string
+	Lines    []Line
+}
+
+// Title renders the hunk header as git prints it, e.g. "@@ -1,4 +1,5 @@ func main".
+func (h Hunk) Title() string {
+	return fmt.Sprintf("@@ -%s +%s @@%s",
+		formatRange(h.OldStart, h.OldLines),
+		formatRange(h.NewStart, h.NewLines),
+		h.Section)
+}
+
+type FileDiff struct {
+	Header []string
+	Hunks  []Hunk
+	Binary bool
+}
+
+// Path returns the path of the file after the change, or before it if the file
+// was deleted.
+func (d FileDiff) Path() string {
+	var path string
+	for _, l := range d.Header {
+		if p, ok := strings.CutPrefix(l, "--- a/"); ok && path == "" {
+			path = p
+		}
+		if p, ok := strings.CutPrefix(l, "+++ b/"); ok {
+			path = p
+		}
+	}
+	if path == "" && len(d.Header) > 0 {
+		// binary files and mode changes have no ---/+++ lines
+		_, b, _ := strings.Cut(d.Header[0], " b/")
+		path = b
+	}
+	return path
+}
+
+// Diff returns the diff of a single path between the index and the worktree, or
+// between HEAD and the index if staged is set.
+func Diff(path string, staged bool) (FileDiff, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	args = append(args, "--", path)
+
+	stdout, err := execGitEnv(noLocks, args...)
+	if err != nil {
+		return FileDiff{}, err
+	}
+
+	diffs := ParseDiff(stdout)
+	if len(diffs) == 0 {
+		return FileDiff{}, nil
+	}
+	return diffs[0], nil
+}
+
+// LineCount is the number of lines a change adds and deletes in a file.
+type LineCount struct {
+	Added   int
+	Deleted int
+	// Binary is set for files that git does not count lines in.
+	Binary bool
+}
+
+// NumStat counts the lines changed in each path between the index and the
+// worktree, or between HEAD and the index if staged is set. Paths are relative
+// to the top level, and renamed files are counted under their new path.
+func NumStat(staged bool) (map[string]LineCount, error) {
+	args := []string{"diff", "--numstat", "-z", "--no-ext-diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	stdout, err := execGitEnv(noLocks, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseNumStat(stdout), nil
+}
+
+func parseNumStat(b []byte) map[string]LineCount {
+	counts := make(map[string]LineCount)
+	fields := strings.Split(string(b), "\x00")
+	for i := 0; i < len(fields); i++ {
+		added, rest, ok := strings.Cut(fields[i], "\t")
+		if !ok {
+			continue
+		}
+		deleted, path, _ := strings.Cut(rest, "\t")
+		// a rename leaves the path empty and is followed by the old and the new
+		// path
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
+
+		var c LineCount
+		if added == "-" {
+			c.Binary = true
+		} else {
+			c.Added, _ = strconv.Atoi(added)
+			c.Deleted, _ = strconv.Atoi(deleted)
+		}
+		counts[path] = c
+	}
+	return counts
+}
+
+// Apply feeds a patch to "git apply", targeting the index instead of the
+// worktree if cached is set.
+func Apply(patch []byte, cached, reverse bool) error {
+	// patches always use paths relative to the top level, but "git apply"
+	// interprets them relative to the working directory
+	root, err := execGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-C", string(root), "apply", "--recount", "--whitespace=nowarn"}
+	if cached {
+		args = append(args, "--cached")
+	}
+	if reverse {
+		args = append(args, "--reverse")
+	}
+	args = append(args, "-")
+
+	_, err = execGitInput(patch, args...)
+	return err
+}
+
+// ParseDiff parses unified diff output as produced by "git diff".
+func ParseDiff(b []byte) []FileDiff {
+	var (
+		diffs []FileDiff
+		cur   *FileDiff
+		hunk  *Hunk
+	)
+
+	for _, l := range strings.Split(string(b), "\n") {
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			diffs = append(diffs, FileDiff{Header: []string{l}})
+			cur = &diffs[len(diffs)-1]
+			hunk = nil
+		case cur == nil:
+			continue
+		case strings.HasPrefix(l, "@@ "):
+			h, ok := parseHunkHeader(l)
+			if !ok {
+				continue
+			}
+			cur.Hunks = append(cur.Hunks, h)
+			hunk = &cur.Hunks[len(cur.Hunks)-1]
+		case hunk == nil:
+			cur.Header = append(cur.Header, l)
+			if strings.HasPrefix(l, "Binary files ") || l == "GIT binary patch" {
+				cur.Binary = true
+			}
+		case strings.HasPrefix(l, "\\"):
+			// "\ No newline at end of file" refers to the preceding line
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+			}
+		case l == "":
+			continue
+		default:
+			kind := LineKind(l[0])
+			if kind != Context && kind != Addition && kind != Deletion {
+				continue
+			}
+			hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: l[1:]})
+		}
+	}
+
+	return diffs
+}
+
+// Patch renders a patch containing a single hunk of the diff. If lines is not
+// nil, only the changed lines whose indices are present in it are included.
+//
+// Unselected changes are either dropped or turned into context, depending on
+// which side of the hunk is the preimage. Reverse must therefore match the
+// direction in which the patch will be applied.
+func (d FileDiff) Patch(hunk int, lines map[int]bool, reverse bool) []byte {
+	h := d.Hunks[hunk]
+
+	selected := make([]Line, 0, len(h.Lines))
+	partial := false
+	for i, l := range h.Lines {
+		if l.Kind == Context || lines == nil || lines[i] {
+			selected = append(selected, l)
+			continue
+		}
+		partial = true
+		if (l.Kind == Deletion && !reverse) || (l.Kind == Addition && reverse) {
+			l.Kind = Context
+			selected = append(selected, l)
+		}
+	}
+
+	h.OldLines, h.NewLines = 0, 0
+	for _, l := range selected {
+		if l.Kind != Addition {
+			h.OldLines++
+		}
+		if l.Kind != Deletion {
+			h.NewLines++
+		}
+	}
+	if h.OldLines > 0 && h.OldStart == 0 {
+		h.OldStart = 1
+	}
+	if h.NewLines > 0 && h.NewStart == 0 {
+		h.NewStart = 1
+	}
+
+	// a line without a newline can only end a side of the patch, so the
+	// marker goes with the last line of each side
+	oldLast, newLast := -1, -1
+	for i, l := range selected {
+		if l.Kind != Addition {
+			oldLast = i
+		}
+		if l.Kind != Deletion {
+			newLast = i
+		}
+	}
+
+	var b strings.Builder
+	for _, l := range d.header(partial) {
+		b.WriteString(l + "\n")
+	}
+	b.WriteString(h.Title() + "\n")
+	for i, l := range selected {
+		switch {
+		case !l.NoNewline:
+			writeLine(&b, l.Kind, l.Text, false)
+		case l.Kind == Context && (i == oldLast) != (i == newLast):
+			// a change turned into context that ends only one side, followed
+			// by lines on the other: that side gets the newline
+			writeLine(&b, Deletion, l.Text, i == oldLast)
+			writeLine(&b, Addition, l.Text, i == newLast)
+		case l.Kind != Addition:
+			writeLine(&b, l.Kind, l.Text, i == oldLast)
+		default:
+			writeLine(&b, l.Kind, l.Text, i == newLast)
+		}
+	}
+	return []byte(b.String())
+}
+
+func writeLine(b *strings.Builder, kind LineKind, text string, noNewline bool) {
+	b.WriteString(string(kind) + text + "\n")
+	if noNewline {
+		b.WriteString("\\ No newline at end of file\n")
+	}
+}
+
+// header returns the file header lines for a patch. A partial patch can neither
+// create nor delete the file, so it is rewritten as a plain modification.
+func (d FileDiff) header(partial bool) []string {
+	if !partial {
+		return d.Header
+	}
+
+	var oldPath, newPath string
+	for _, l := range d.Header {
+		if p, ok := strings.CutPrefix(l, "--- "); ok && p != "/dev/null" {
+			oldPath = p
+		}
+		if p, ok := strings.CutPrefix(l, "+++ "); ok && p != "/dev/null" {
+			newPath = p
+		}
+	}
+	if oldPath == "" {
+		oldPath = "a/" + strings.TrimPrefix(newPath, "b/")
+	}
+	if newPath == "" {
+		newPath = "b/" + strings.TrimPrefix(oldPath, "a/")
+	}
+
+	header := make([]string, 0, len(d.Header))
+	for _, l := range d.Header {
+		switch {
+		case strings.HasPrefix(l, "new file mode "),
+			strings.HasPrefix(l, "deleted file mode "),
+			strings.HasPrefix(l, "index "):
+			continue
+		case strings.HasPrefix(l, "--- "):
+			header = append(header, "--- "+oldPath)
+		case strings.HasPrefix(l, "+++ "):
+			header = append(header, "+++ "+newPath)
+		default:
+			header = append(header, l)
+		}
+	}
+	return header
+}
+
+func parseHunkHeader(l string) (Hunk, bool) {
+	var h Hunk
+
+	fields := strings.SplitN(l, " ", 4)
+	if len(fields) < 4 || fields[3][:min(2, len(fields[3]))] != "@@" {
+		return h, false
+	}
+
+	var ok bool
+	if h.OldStart, h.OldLines, ok = parseRange(fields[1], "-"); !ok {
+		return h, false
+	}
+	if h.NewStart, h.NewLines, ok = parseRange(fields[2], "+"); !ok {
+		return h, false
+	}
+	h.Section = fields[3][2:]
+	return h, true
+}
+
+func parseRange(s, prefix string) (int, int, bool) {
+	s, ok := strings.CutPrefix(s, prefix)
+	if !ok {
+		return 0, 0, false
+	}
+
+	start, count, found := strings.Cut(s, ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !found {
+		return n, 1, true
+	}
+	c, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, c, true
+}
+
+func formatRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
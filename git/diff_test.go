@@ -0,0 +1,185 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testDiff = `diff --git a/f.txt b/f.txt
+index e8823e1..2dbd2c6 100644
+--- a/f.txt
++++ b/f.txt
+@@ -1,4 +1,4 @@ func main
+ 1
+-2
++two
+ 3
+ 4
+@@ -10,2 +10,3 @@
+ 10
++extra
+ 11
+\ No newline at end of file
+`
+
+func TestParseDiff(t *testing.T) {
+	t.Parallel()
+
+	diffs := ParseDiff([]byte(testDiff))
+	if len(diffs) != 1 {
+		t.Fatalf("got %d diffs, want 1", len(diffs))
+	}
+
+	d := diffs[0]
+	if len(d.Header) != 4 || len(d.Hunks) != 2 {
+		t.Fatalf("got %d header lines and %d hunks, want 4 and 2", len(d.Header), len(d.Hunks))
+	}
+	if got := d.Path(); got != "f.txt" {
+		t.Errorf("path = %q", got)
+	}
+	if got := d.Hunks[0].Title(); got != "@@ -1,4 +1,4 @@ func main" {
+		t.Errorf("title = %q", got)
+	}
+	if h := d.Hunks[1]; h.OldStart != 10 || h.OldLines != 2 || h.NewStart != 10 || h.NewLines != 3 {
+		t.Errorf("second hunk range = %+v", h)
+	}
+	if l := d.Hunks[1].Lines[2]; l.Text != "11" || !l.NoNewline {
+		t.Errorf("last line = %+v, want 11 without newline", l)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	d := ParseDiff([]byte(testDiff))[0]
+	header := "diff --git a/f.txt b/f.txt\nindex e8823e1..2dbd2c6 100644\n--- a/f.txt\n+++ b/f.txt\n"
+
+	tests := []struct {
+		name    string
+		hunk    int
+		lines   map[int]bool
+		reverse bool
+		want    string
+	}{
+		{
+			name: "whole hunk",
+			hunk: 1,
+			want: "@@ -10,2 +10,3 @@\n 10\n+extra\n 11\n\\ No newline at end of file\n",
+		},
+		{
+			name:  "only deletion",
+			hunk:  0,
+			lines: map[int]bool{1: true},
+			want:  "@@ -1,4 +1,3 @@ func main\n 1\n-2\n 3\n 4\n",
+		},
+		{
+			name:  "only addition",
+			hunk:  0,
+			lines: map[int]bool{2: true},
+			want:  "@@ -1,4 +1,5 @@ func main\n 1\n 2\n+two\n 3\n 4\n",
+		},
+		{
+			name:    "only addition in reverse",
+			hunk:    0,
+			lines:   map[int]bool{2: true},
+			reverse: true,
+			want:    "@@ -1,3 +1,4 @@ func main\n 1\n+two\n 3\n 4\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := string(d.Patch(tt.hunk, tt.lines, tt.reverse))
+			// partial patches drop the index line
+			wantHeader := header
+			if tt.lines != nil {
+				wantHeader = "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n"
+			}
+			if got != wantHeader+tt.want {
+				t.Errorf("patch =\n%s\nwant\n%s", got, wantHeader+tt.want)
+			}
+		})
+	}
+}
+
+// testEOFDiff changes the last line of a file that ends without a newline.
+const testEOFDiff = `diff --git a/f.txt b/f.txt
+index 1c1b9ea..02ef9f5 100644
+--- a/f.txt
++++ b/f.txt
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+\ No newline at end of file
+`
+
+func TestPatchNoNewline(t *testing.T) {
+	t.Parallel()
+
+	d := ParseDiff([]byte(testEOFDiff))[0]
+	const noNewline = "\\ No newline at end of file\n"
+
+	tests := []struct {
+		name    string
+		lines   map[int]bool
+		reverse bool
+		want    string
+	}{
+		{
+			name:  "only addition",
+			lines: map[int]bool{2: true},
+			// b is no longer the last line, and gets a newline
+			want: "@@ -1,2 +1,3 @@\n a\n-b\n" + noNewline + "+b\n+c\n" + noNewline,
+		},
+		{
+			name:  "only deletion",
+			lines: map[int]bool{1: true},
+			want:  "@@ -1,2 +1 @@\n a\n-b\n" + noNewline,
+		},
+		{
+			name:    "only addition in reverse",
+			lines:   map[int]bool{2: true},
+			reverse: true,
+			want:    "@@ -1 +1,2 @@\n a\n+c\n" + noNewline,
+		},
+		{
+			name:    "only deletion in reverse",
+			lines:   map[int]bool{1: true},
+			reverse: true,
+			want:    "@@ -1,3 +1,2 @@\n a\n-b\n c\n" + noNewline,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := string(d.Patch(0, tt.lines, tt.reverse))
+			want := "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n" + tt.want
+			if got != want {
+				t.Errorf("patch =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseNumStat(t *testing.T) {
+	t.Parallel()
+
+	// the trailing NUL is trimmed along with the output
+	out := "3\t1\ta.go\x00-\t-\timg.png\x000\t2\t\x00old name.go\x00new name.go"
+	got := parseNumStat([]byte(out))
+
+	want := map[string]LineCount{
+		"a.go":        {Added: 3, Deleted: 1},
+		"img.png":     {Binary: true},
+		"new name.go": {Deleted: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package git
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// ErrNoRepository is returned when the working directory is not inside a git
+// repository.
+var ErrNoRepository = errors.New("not a git repository")
+
+// ErrNoUpstream is returned when the current branch has no upstream configured.
+var ErrNoUpstream = errors.New("no upstream configured")
+
+// Error is returned when a git command fails. It carries the arguments the
+// command was run with and whatever git wrote to stderr.
+type Error struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+	// git prefixes most messages with "fatal: " or "error: ", which adds nothing
+	// once we say which command failed
+	for _, prefix := range []string{"fatal: ", "error: "} {
+		msg = strings.TrimPrefix(msg, prefix)
+	}
+	return "git " + e.subcommand() + ": " + msg
+}
+
+// subcommand returns the name of the git command, skipping any global options
+// that precede it.
+func (e *Error) subcommand() string {
+	for i := 0; i < len(e.Args); i++ {
+		switch {
+		case e.Args[i] == "-C" || e.Args[i] == "-c":
+			i++
+		case !strings.HasPrefix(e.Args[i], "-"):
+			return e.Args[i]
+		}
+	}
+	return strings.Join(e.Args, " ")
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code of the git process, or -1 if it did not run to
+// completion.
+func (e *Error) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,289 @@
+package git
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Fake is an in-memory Backend for tests. Add, Unstage, Restore, StashPush,
+// Take and MarkResolved record the paths they are called with and update Files
+// the way git would, roughly. Snapshot and SnapshotStaged record their paths
+// without changing anything. Continue, Skip and Abort record what they were
+// called for in Ops, and finish the operation in State unless it is skipped.
+// Apply and Commit record what they are given; Commit also drops the staged
+// changes from Files and moves HEAD, one commit further ahead of the upstream
+// in Tracking unless it amends.
+type Fake struct {
+	State  RepoState
+	Branch BranchInfo
+	Files  []FileStatus
+	// Tracking is what AheadBehind returns, or ErrNoUpstream if it has no
+	// upstream.
+	Tracking Tracking
+	// Ignored is what IgnoredDirs returns.
+	Ignored []string
+	// Lines is what NumStat returns, for staged and unstaged changes alike.
+	Lines map[string]LineCount
+	// Diffs and StagedDiffs are what Diff returns for unstaged and staged
+	// changes, by path.
+	Diffs       map[string]FileDiff
+	StagedDiffs map[string]FileDiff
+	// Message is what LastCommitMessage returns.
+	Message string
+	// Err, if set, is returned by every operation.
+	Err error
+
+	Added    []string
+	Unstaged []string
+	Restored []string
+	Stashed  []string
+	Ours     []string
+	Theirs   []string
+	Resolved []string
+	Ops      []string
+	// Snapshots holds the paths of each snapshot, in the order they were taken.
+	Snapshots [][]string
+	// StagedSnapshots holds the paths of each snapshot of staged content.
+	StagedSnapshots [][]string
+	Patches         []FakePatch
+	Commits         []CommitOptions
+}
+
+// FakePatch is a patch given to Fake.Apply.
+type FakePatch struct {
+	Patch   string
+	Cached  bool
+	Reverse bool
+}
+
+func (f *Fake) CurrentRef() (RepoState, error) {
+	return f.State, f.Err
+}
+
+func (f *Fake) GitDir() (string, error) {
+	return f.State.Dir + "/.git", f.Err
+}
+
+func (f *Fake) IgnoredDirs(root string) ([]string, error) {
+	return f.Ignored, f.Err
+}
+
+func (f *Fake) Status(opts StatusOptions) (StatusInfo, error) {
+	if f.Err != nil {
+		return StatusInfo{}, f.Err
+	}
+	branch := f.Branch
+	if opts.NoAheadBehind {
+		branch.Ahead, branch.Behind = 0, 0
+	}
+	return StatusInfo{Branch: branch, Files: slices.Clone(f.Files)}, nil
+}
+
+func (f *Fake) AheadBehind(branch string) (Tracking, error) {
+	if f.Err == nil && f.Tracking.Upstream == "" {
+		return Tracking{}, ErrNoUpstream
+	}
+	return f.Tracking, f.Err
+}
+
+func (f *Fake) NumStat(staged bool) (map[string]LineCount, error) {
+	return f.Lines, f.Err
+}
+
+func (f *Fake) Diff(path string, staged bool) (FileDiff, error) {
+	diffs := f.Diffs
+	if staged {
+		diffs = f.StagedDiffs
+	}
+	return diffs[strings.TrimPrefix(path, f.State.Dir+"/")], f.Err
+}
+
+func (f *Fake) Apply(patch []byte, cached, reverse bool) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Patches = append(f.Patches, FakePatch{Patch: string(patch), Cached: cached, Reverse: reverse})
+	return nil
+}
+
+func (f *Fake) Add(paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Added = append(f.Added, paths...)
+	f.update(paths, func(v *FileStatus) {
+		switch v.Tracked {
+		case Unmodified:
+		case Untracked:
+			v.Staged, v.Tracked = Added, Unmodified
+		default:
+			if v.Staged != Added {
+				v.Staged = v.Tracked
+			}
+			v.Tracked = Unmodified
+		}
+	})
+	return nil
+}
+
+func (f *Fake) Unstage(paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Unstaged = append(f.Unstaged, paths...)
+	f.update(paths, func(v *FileStatus) {
+		switch {
+		case v.Staged == Added:
+			v.Staged, v.Tracked = Untracked, Untracked
+		case v.Staged != Unmodified:
+			if v.Tracked == Unmodified {
+				v.Tracked = v.Staged
+			}
+			v.Staged = Unmodified
+		}
+	})
+	return nil
+}
+
+func (f *Fake) Restore(paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Restored = append(f.Restored, paths...)
+	f.update(paths, func(v *FileStatus) {
+		if v.Tracked != Untracked {
+			v.Tracked = Unmodified
+		}
+	})
+	return nil
+}
+
+func (f *Fake) StashPush(message string, paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Stashed = append(f.Stashed, paths...)
+	f.update(paths, func(v *FileStatus) {
+		v.Staged, v.Tracked = Unmodified, Unmodified
+	})
+	return nil
+}
+
+func (f *Fake) Snapshot(message string, paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Snapshots = append(f.Snapshots, paths)
+	return nil
+}
+
+func (f *Fake) SnapshotStaged(message string, paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.StagedSnapshots = append(f.StagedSnapshots, paths)
+	return nil
+}
+
+func (f *Fake) Take(path string, c Conflict, side Side) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	if side == Ours {
+		f.Ours = append(f.Ours, path)
+	} else {
+		f.Theirs = append(f.Theirs, path)
+	}
+	f.update([]string{path}, func(v *FileStatus) {
+		v.Staged, v.Tracked = Modified, Unmodified
+		if !c.has(side) {
+			v.Staged = Deleted
+		}
+		v.StageIDs = [3]string{}
+	})
+	return nil
+}
+
+func (f *Fake) MarkResolved(paths ...string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Resolved = append(f.Resolved, paths...)
+	f.update(paths, func(v *FileStatus) {
+		v.Staged, v.Tracked = Modified, Unmodified
+		v.StageIDs = [3]string{}
+	})
+	return nil
+}
+
+func (f *Fake) Operation() (Operation, error) {
+	return f.State.Operation, f.Err
+}
+
+func (f *Fake) Continue(k OpKind) error {
+	return f.op(k, "continue")
+}
+
+func (f *Fake) Skip(k OpKind) error {
+	return f.op(k, "skip")
+}
+
+func (f *Fake) Abort(k OpKind) error {
+	return f.op(k, "abort")
+}
+
+func (f *Fake) op(k OpKind, action string) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Ops = append(f.Ops, k.String()+" "+action)
+	if action != "skip" {
+		f.State.Operation = Operation{}
+	}
+	return nil
+}
+
+func (f *Fake) Commit(opts CommitOptions) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Commits = append(f.Commits, opts)
+	f.Branch.OID = fmt.Sprintf("%040x", len(f.Commits))
+	f.State.Ref = f.Branch.OID
+	if !opts.Amend && f.Tracking.Upstream != "" {
+		f.Tracking.Ahead++
+	}
+	for i := range f.Files {
+		if f.Files[i].Staged != Untracked && !f.Files[i].Unmerged() {
+			f.Files[i].Staged = Unmodified
+		}
+	}
+	f.prune()
+	return nil
+}
+
+func (f *Fake) LastCommitMessage() (string, error) {
+	return f.Message, f.Err
+}
+
+// update applies fn to the files at the given paths, which may be absolute or
+// relative to the top level, and drops files that end up unmodified.
+func (f *Fake) update(paths []string, fn func(*FileStatus)) {
+	for _, p := range paths {
+		p = strings.TrimPrefix(p, f.State.Dir+"/")
+		for i := range f.Files {
+			if f.Files[i].Path == p {
+				fn(&f.Files[i])
+			}
+		}
+	}
+	f.prune()
+}
+
+// prune drops the files that have no changes left.
+func (f *Fake) prune() {
+	f.Files = slices.DeleteFunc(f.Files, func(v FileStatus) bool {
+		return v.Staged == Unmodified && v.Tracked == Unmodified
+	})
+}
@@ -1,16 +1,63 @@
 package git
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
 func execGit(args ...string) ([]byte, error) {
-	stdout, err := exec.Command("git", args...).Output()
+	return execGitInput(nil, args...)
+}
+
+func execGitInput(stdin []byte, args ...string) ([]byte, error) {
+	return run(stdin, nil, args...)
+}
+
+// noLocks keeps git from refreshing the index while it only reads the
+// worktree. The status view watches the index, and would otherwise reload
+// after every read of its own.
+var noLocks = []string{"GIT_OPTIONAL_LOCKS=0"}
+
+// untranslated has git write its messages in English, for the few that are
+// told apart by their text: git gives them no exit code of their own.
+var untranslated = []string{"LC_ALL=C"}
+
+// execGitEnv runs git with variables added to its environment.
+func execGitEnv(env []string, args ...string) ([]byte, error) {
+	return run(nil, env, args...)
+}
+
+// execGitTerminal runs git on the terminal, for commands that may open the
+// editor. Only the error output is kept, for the error.
+func execGitTerminal(args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &Error{Args: args, Stderr: stderr.String(), Err: err}
+	}
+	return nil
+}
+
+func run(stdin []byte, env []string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
 	if err != nil {
-		log.Printf("Error while executing command:\ngit %s\n\n%v\n\n", strings.Join(args, " "), err)
-		return nil, err
+		return nil, &Error{Args: args, Stderr: stderr.String(), Err: err}
 	}
 	if len(stdout) > 0 {
 		// trim trailing newline
@@ -19,13 +66,6 @@ func execGit(args ...string) ([]byte, error) {
 	return stdout, nil
 }
 
-type statusline struct {
-	Path    string
-	Staged  byte
-	Tracked byte
-	Extra   string
-}
-
 type StatusCode byte
 
 const (
@@ -43,108 +83,126 @@ type RepoState struct {
 	Ref    string
 	Branch string
 	Dir    string
+	// Operation is the operation in progress, such as a rebase stopped at a
+	// conflict.
+	Operation Operation
 }
 
-func CurrentRef() RepoState {
+func CurrentRef() (RepoState, error) {
 	var state RepoState
 
-	stdout, err := execGit("rev-parse", "--show-toplevel", "--short", "HEAD")
+	stdout, err := execGitEnv(untranslated, "rev-parse", "--show-toplevel", "--short", "HEAD")
 	if err != nil {
-		log.Fatalf("Failed to get output from \"git rev-parse\": %v\n", err)
-	} else {
-		lines := strings.Split(string(stdout), "\n")
-		state.Dir = lines[0]
-		state.Ref = lines[1]
+		var gitErr *Error
+		if errors.As(err, &gitErr) && strings.Contains(gitErr.Stderr, "not a git repository") {
+			return state, ErrNoRepository
+		}
+		return state, err
 	}
+	lines := strings.Split(string(stdout), "\n")
+	state.Dir = lines[0]
+	state.Ref = lines[1]
 
 	stdout, err = execGit("branch", "--show-current")
 	if err != nil {
-		log.Fatalf("Failed to get output from \"git branch\": %v\n", err)
-	} else {
-		state.Branch = strings.Split(string(stdout), "\n")[0]
+		return state, err
 	}
+	state.Branch = strings.Split(string(stdout), "\n")[0]
+
+	state.Operation, err = CurrentOperation()
+	return state, err
+}
 
-	return state
+func Add(paths ...string) error {
+	args := append([]string{"add"}, paths...)
+	_, err := execGit(args...)
+	return err
+}
+
+func Unstage(paths ...string) error {
+	args := append([]string{"restore", "--staged"}, paths...)
+	_, err := execGit(args...)
+	return err
 }
 
-func Status() []statusline {
-	stdout, err := execGit("status", "--porcelain")
+func Restore(paths ...string) error {
+	args := append([]string{"restore"}, paths...)
+	_, err := execGit(args...)
+	return err
+}
+
+// Tracking describes how a branch relates to its upstream.
+type Tracking struct {
+	Upstream string
+	// Gone is set if the upstream is configured but no longer exists, for
+	// example because it was deleted on the remote and then pruned.
+	Gone   bool
+	Ahead  int
+	Behind int
+}
+
+// AheadBehind counts the commits on branch that are not on its upstream, and
+// vice versa. It returns ErrNoUpstream if branch does not track anything.
+func AheadBehind(branch string) (Tracking, error) {
+	var tracking Tracking
+
+	stdout, err := execGit("for-each-ref", "--format=%(upstream:short)", "refs/heads/"+branch)
 	if err != nil {
-		log.Fatalf("Failed to get output from \"git status\": %v\n", err)
+		return tracking, err
 	}
 	if len(stdout) == 0 {
-		return nil
+		return tracking, ErrNoUpstream
 	}
+	tracking.Upstream = string(stdout)
 
-	lines := strings.Split(string(stdout), "\n")
-	files := make([]statusline, 0, len(lines))
-	for _, v := range lines {
-		if v == "" {
-			continue
-		}
-
-		file := statusline{
-			Path:    v[3:],
-			Staged:  v[0],
-			Tracked: v[1],
-		}
-
-		// if renamed (R) set "extra" to the previous name and "path" to the new name
-		if file.Staged == 82 {
-			parts := strings.Split(file.Path, " -> ")
-			file.Path = parts[1]
-			file.Extra = parts[0]
-		}
+	_, err = execGit("rev-parse", "--verify", "--quiet", branch+"@{upstream}")
+	tracking.Gone = err != nil
+	if tracking.Gone {
+		return tracking, nil
+	}
 
-		files = append(files, file)
+	stdout, err = execGit("rev-list", "--left-right", "--count", "refs/heads/"+branch+"..."+branch+"@{upstream}")
+	if err != nil {
+		return tracking, err
 	}
-	return files
+	ahead, behind, _ := strings.Cut(string(stdout), "\t")
+	tracking.Ahead, _ = strconv.Atoi(ahead)
+	tracking.Behind, _ = strconv.Atoi(behind)
+	return tracking, nil
 }
 
-func Add(paths ...string) {
-	args := append([]string{"add"}, paths...)
-	_, err := execGit(args...)
+// GitDir returns the absolute path of the git directory of the current
+// worktree.
+func GitDir() (string, error) {
+	stdout, err := execGit("rev-parse", "--absolute-git-dir")
 	if err != nil {
-		log.Fatalf("Failed to stage files:%v\n", err)
+		return "", err
 	}
+	return string(stdout), nil
 }
 
-func Unstage(paths ...string) {
-	args := append([]string{"restore", "--staged"}, paths...)
-	_, err := execGit(args...)
+// CurrentOperation detects the operation in progress in the current worktree.
+func CurrentOperation() (Operation, error) {
+	gitDir, err := GitDir()
 	if err != nil {
-		log.Fatalf("Error unstaging file: %v\n", err)
+		return Operation{}, err
 	}
+	return InProgress(gitDir), nil
 }
 
-func Restore(paths ...string) {
-	args := append([]string{"restore"}, paths...)
-	_, err := execGit(args...)
+// IgnoredDirs returns the directories below root that are ignored as a whole,
+// relative to root and with a trailing slash.
+func IgnoredDirs(root string) ([]string, error) {
+	stdout, err := execGit("-C", root, "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory")
 	if err != nil {
-		log.Fatalf("Error restoring file: %v\n", err)
+		return nil, err
 	}
-}
 
-func AheadBehind(branch string) (int, int) {
-	// TODO: doing this synchronously impacts startup time. Consider a routine-based approach.
-	return 0, 0
-
-	// remotebytes, err := execGit("remote")
-	// if err != nil {
-	// 	return 0, 0
-	// }
-	// remote := string(remotebytes)
-	// compareTo := remote + "/" + branch
-	// remoteBranchExists, err := execGit("ls-remote", "--heads", remote, "refs/heads/"+branch)
-	// if err != nil || len(remoteBranchExists) == 0 {
-	// 	return 0, 0
-	// }
-
-	// revlistBytes, err := execGit("rev-list", "--left-right", "--count", branch+"..."+compareTo)
-	// if err != nil {
-	// 	return 0, 0
-	// }
-	// ahead, _ := strconv.Atoi(string(revlistBytes[0]))
-	// behind, _ := strconv.Atoi(string(revlistBytes[len(revlistBytes)-1]))
-	// return ahead, behind
+	var dirs []string
+	for _, v := range strings.Split(string(stdout), "\x00") {
+		if strings.HasSuffix(v, "/") {
+			dirs = append(dirs, v)
+		}
+	}
+	return dirs, nil
 }
@@ -0,0 +1,178 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RefKind byte
+
+const (
+	RefHead RefKind = iota
+	RefBranch
+	RefRemote
+	RefTag
+)
+
+// Ref is a ref pointing at a commit, as shown in the decorations of a log.
+type Ref struct {
+	Name string
+	Kind RefKind
+}
+
+// LogEntry is a line of a log: a commit, or a line of the graph between
+// commits.
+type LogEntry struct {
+	// Graph is the part of the branch graph drawn on the line.
+	Graph   string
+	OID     string
+	Author  string
+	Date    time.Time
+	Refs    []Ref
+	Subject string
+}
+
+// LogOptions select the commits to list.
+type LogOptions struct {
+	// All lists the commits reachable from any ref instead of only from HEAD.
+	All bool
+	// Max limits the number of commits, if not zero.
+	Max int
+}
+
+// Log lists commits with a branch graph, most recent first. Lines of the graph
+// that only connect commits are returned as entries with an empty OID.
+func Log(opts LogOptions) ([]LogEntry, error) {
+	args := []string{"log", "--graph", "--decorate=full", "--no-color",
+		"--format=%x1f%H%x1f%an%x1f%at%x1f%D%x1f%s"}
+	if opts.All {
+		args = append(args, "--all")
+	}
+	if opts.Max > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.Max))
+	}
+
+	stdout, err := execGit(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(stdout), nil
+}
+
+func parseLog(b []byte) []LogEntry {
+	var entries []LogEntry
+	for _, l := range strings.Split(string(b), "\n") {
+		if l == "" {
+			continue
+		}
+		fields := strings.Split(l, "\x1f")
+		entry := LogEntry{Graph: strings.TrimRight(fields[0], " ")}
+		if len(fields) >= 6 {
+			entry.OID = fields[1]
+			entry.Author = fields[2]
+			if secs, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+				entry.Date = time.Unix(secs, 0)
+			}
+			entry.Refs = parseDecorations(fields[4])
+			entry.Subject = fields[5]
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseDecorations reads the refs of a commit from full decorations, e.g.
+// "HEAD -> refs/heads/main, tag: refs/tags/v1.0".
+func parseDecorations(s string) []Ref {
+	var refs []Ref
+	for _, d := range strings.Split(s, ", ") {
+		if d == "" {
+			continue
+		}
+		if branch, ok := strings.CutPrefix(d, "HEAD -> "); ok {
+			refs = append(refs, Ref{Name: "HEAD", Kind: RefHead})
+			d = branch
+		}
+		d = strings.TrimPrefix(d, "tag: ")
+
+		switch {
+		case d == "HEAD":
+			refs = append(refs, Ref{Name: d, Kind: RefHead})
+		case strings.HasPrefix(d, "refs/heads/"):
+			refs = append(refs, Ref{Name: strings.TrimPrefix(d, "refs/heads/"), Kind: RefBranch})
+		case strings.HasPrefix(d, "refs/remotes/"):
+			// the remote HEAD only says which branch is the default
+			if strings.HasSuffix(d, "/HEAD") {
+				continue
+			}
+			refs = append(refs, Ref{Name: strings.TrimPrefix(d, "refs/remotes/"), Kind: RefRemote})
+		case strings.HasPrefix(d, "refs/tags/"):
+			refs = append(refs, Ref{Name: strings.TrimPrefix(d, "refs/tags/"), Kind: RefTag})
+		}
+	}
+	return refs
+}
+
+// FileStat counts the lines changed in a file. Both counts are -1 for binary
+// files.
+type FileStat struct {
+	Path      string
+	Additions int
+	Deletions int
+}
+
+// CommitDetail returns the full message of a commit and the lines it changed
+// per file. Merges are compared to their first parent.
+func CommitDetail(oid string) (string, []FileStat, error) {
+	stdout, err := execGit("show", "--no-color", "--diff-merges=first-parent", "--format=%B%x00", "--numstat", oid)
+	if err != nil {
+		return "", nil, err
+	}
+
+	message, numstat, _ := strings.Cut(string(stdout), "\x00")
+	var stats []FileStat
+	for _, l := range strings.Split(numstat, "\n") {
+		fields := strings.SplitN(l, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		stat := FileStat{Path: fields[2], Additions: -1, Deletions: -1}
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			stat.Additions = n
+		}
+		if n, err := strconv.Atoi(fields[1]); err == nil {
+			stat.Deletions = n
+		}
+		stats = append(stats, stat)
+	}
+	return strings.TrimSpace(message), stats, nil
+}
+
+// CommitDiff returns the changes a commit made. Merges are compared to their
+// first parent.
+func CommitDiff(oid string) ([]FileDiff, error) {
+	stdout, err := execGit("show", "--format=", "--patch", "--diff-merges=first-parent",
+		"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", oid)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiff(stdout), nil
+}
+
+// CheckoutDetached checks out a commit without a branch.
+func CheckoutDetached(oid string) error {
+	_, err := execGit("switch", "--detach", oid)
+	return err
+}
+
+func CherryPick(oid string) error {
+	_, err := execGit("cherry-pick", oid)
+	return err
+}
+
+// Revert commits the inverse of a commit, with the message git generates.
+func Revert(oid string) error {
+	_, err := execGit("revert", "--no-edit", oid)
+	return err
+}
@@ -0,0 +1,38 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLog(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		"*\x1f1111111111111111111111111111111111111111\x1fAnn\x1f1700000000\x1fHEAD -> refs/heads/main, tag: refs/tags/v1, refs/remotes/origin/HEAD, refs/remotes/origin/main\x1fMerge branch 'topic'",
+		"|\\",
+		"| *\x1f2222222222222222222222222222222222222222\x1fBob\x1f1700000000\x1f\x1ftopic work",
+	}
+	entries := parseLog([]byte(strings.Join(lines, "\n") + "\n"))
+
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if e := entries[1]; e.Graph != "|\\" || e.OID != "" {
+		t.Errorf("graph line = %+v", e)
+	}
+	if e := entries[2]; e.Graph != "| *" || e.Author != "Bob" || e.Subject != "topic work" || e.Refs != nil {
+		t.Errorf("second commit = %+v", e)
+	}
+
+	want := []Ref{
+		{Name: "HEAD", Kind: RefHead},
+		{Name: "main", Kind: RefBranch},
+		{Name: "v1", Kind: RefTag},
+		{Name: "origin/main", Kind: RefRemote},
+	}
+	if got := entries[0].Refs; !reflect.DeepEqual(got, want) {
+		t.Errorf("refs = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OpKind is a kind of operation that stops and waits for the user, e.g. to
+// resolve conflicts.
+type OpKind byte
+
+const (
+	OpNone OpKind = iota
+	OpMerge
+	OpRebase
+	OpCherryPick
+	OpRevert
+	OpBisect
+	OpApplyMailbox
+)
+
+func (k OpKind) String() string {
+	return [...]string{"", "merge", "rebase", "cherry-pick", "revert", "bisect", "am"}[k]
+}
+
+// CanContinue reports whether the operation can be continued once the user is
+// done. A bisection only moves on with a verdict on the commit.
+func (k OpKind) CanContinue() bool {
+	return k != OpNone && k != OpBisect
+}
+
+// CanSkip reports whether the current step of the operation can be skipped.
+func (k OpKind) CanSkip() bool {
+	return k != OpNone && k != OpMerge
+}
+
+// Operation describes the operation in progress in a repository, if any.
+type Operation struct {
+	Kind OpKind
+	// Head is the branch the operation works on, if known: the branch being
+	// rebased, or the one a bisection started from.
+	Head string
+	// Onto is where a rebase replays commits, as a ref name where possible.
+	Onto string
+	// Commit is the commit being merged, picked or reverted, as a ref name where
+	// possible.
+	Commit string
+	// Step and Total count the commits or patches of a rebase or am session.
+	Step  int
+	Total int
+}
+
+// InProgress detects the operation in progress from the state files git keeps
+// in gitDir.
+func InProgress(gitDir string) Operation {
+	read := func(name string) (string, bool) {
+		b, err := os.ReadFile(filepath.Join(gitDir, name))
+		return strings.TrimSpace(string(b)), err == nil
+	}
+	count := func(name string) int {
+		s, _ := read(name)
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	branch := func(name string) string {
+		s, _ := read(name)
+		return strings.TrimPrefix(s, "refs/heads/")
+	}
+
+	var op Operation
+	switch {
+	case isDir(filepath.Join(gitDir, "rebase-merge")):
+		op = Operation{
+			Kind:  OpRebase,
+			Head:  branch("rebase-merge/head-name"),
+			Step:  count("rebase-merge/msgnum"),
+			Total: count("rebase-merge/end"),
+		}
+		if onto, ok := read("rebase-merge/onto"); ok {
+			op.Onto = describe(onto)
+		}
+	case isDir(filepath.Join(gitDir, "rebase-apply")):
+		op = Operation{
+			Kind:  OpRebase,
+			Head:  branch("rebase-apply/head-name"),
+			Step:  count("rebase-apply/next"),
+			Total: count("rebase-apply/last"),
+		}
+		if _, ok := read("rebase-apply/applying"); ok {
+			op.Kind = OpApplyMailbox
+		} else if onto, ok := read("rebase-apply/onto"); ok {
+			op.Onto = describe(onto)
+		}
+	case exists(filepath.Join(gitDir, "MERGE_HEAD")):
+		op = Operation{Kind: OpMerge}
+		if oid, ok := read("MERGE_HEAD"); ok {
+			// an octopus merge lists several heads, one per line
+			oid, _, _ = strings.Cut(oid, "\n")
+			op.Commit = describe(oid)
+		}
+	case exists(filepath.Join(gitDir, "CHERRY_PICK_HEAD")):
+		oid, _ := read("CHERRY_PICK_HEAD")
+		op = Operation{Kind: OpCherryPick, Commit: describe(oid)}
+	case exists(filepath.Join(gitDir, "REVERT_HEAD")):
+		oid, _ := read("REVERT_HEAD")
+		op = Operation{Kind: OpRevert, Commit: describe(oid)}
+	case exists(filepath.Join(gitDir, "BISECT_LOG")):
+		op = Operation{Kind: OpBisect, Head: branch("BISECT_START")}
+	}
+	// "detached HEAD" is written in place of a branch name
+	if op.Head == "detached HEAD" {
+		op.Head = ""
+	}
+	return op
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// describe names a commit after a branch, remote branch or tag pointing at it,
+// or else abbreviates it.
+func describe(oid string) string {
+	stdout, err := execGit("for-each-ref", "--points-at="+oid, "--format=%(refname:short)",
+		"refs/heads", "refs/remotes", "refs/tags")
+	if err == nil && len(stdout) > 0 {
+		name, _, _ := strings.Cut(string(stdout), "\n")
+		return name
+	}
+	return oid[:min(7, len(oid))]
+}
+
+// Continue carries on with an operation after the user resolved what stopped
+// it. Git runs on the terminal, where it opens the editor if the commit message
+// is to be edited, as it does for merges and reworded commits.
+func Continue(k OpKind) error {
+	return execGitTerminal(k.String(), "--continue")
+}
+
+// Skip skips the commit or patch an operation stopped at. In a bisection, it
+// skips testing the current commit.
+func Skip(k OpKind) error {
+	if k == OpBisect {
+		_, err := execGit("bisect", "skip")
+		return err
+	}
+	_, err := execGit(k.String(), "--skip")
+	return err
+}
+
+// Abort stops an operation and goes back to where it started.
+func Abort(k OpKind) error {
+	if k == OpBisect {
+		_, err := execGit("bisect", "reset")
+		return err
+	}
+	_, err := execGit(k.String(), "--abort")
+	return err
+}
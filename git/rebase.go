@@ -0,0 +1,96 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Commands of a rebase todo list that act on a commit.
+const (
+	Pick   = "pick"
+	Reword = "reword"
+	Edit   = "edit"
+	Squash = "squash"
+	Fixup  = "fixup"
+	Drop   = "drop"
+	Exec   = "exec"
+)
+
+// todoCommands expands the abbreviations git accepts in a todo list.
+var todoCommands = map[string]string{
+	"p": Pick, "r": Reword, "e": Edit, "s": Squash, "f": Fixup, "d": Drop, "x": Exec,
+	"b": "break", "l": "label", "t": "reset", "m": "merge", "u": "update-ref",
+}
+
+// TodoLine is a line of a rebase todo list. Lines that do not act on a single
+// commit, such as exec, label or merge, have no OID and keep everything after
+// the command in Text.
+type TodoLine struct {
+	Command string
+	OID     string
+	// Text is the subject of the commit, or the arguments of other commands.
+	Text string
+}
+
+// ParseTodo reads a rebase todo list, dropping comments and blank lines.
+func ParseTodo(data string) []TodoLine {
+	var todo []TodoLine
+	for _, l := range strings.Split(data, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		command, rest, _ := strings.Cut(l, " ")
+		if full, ok := todoCommands[command]; ok {
+			command = full
+		}
+		line := TodoLine{Command: command, Text: strings.TrimSpace(rest)}
+		switch command {
+		case Pick, Reword, Edit, Squash, Fixup, Drop:
+			// "fixup -C" and "-c" take a flag before the commit, which is kept as
+			// part of the text
+			if !strings.HasPrefix(line.Text, "-") {
+				line.OID, line.Text, _ = strings.Cut(line.Text, " ")
+			}
+		}
+		todo = append(todo, line)
+	}
+	return todo
+}
+
+// FormatTodo writes a todo list back in the form git reads.
+func FormatTodo(todo []TodoLine) string {
+	var b strings.Builder
+	for _, l := range todo {
+		parts := []string{l.Command}
+		if l.OID != "" {
+			parts = append(parts, l.OID)
+		}
+		if l.Text != "" {
+			parts = append(parts, l.Text)
+		}
+		b.WriteString(strings.Join(parts, " ") + "\n")
+	}
+	return b.String()
+}
+
+// RebaseInteractive runs "git rebase -i" on the terminal, with editor as the
+// sequence editor that is handed the todo list. An empty base rebases onto the
+// upstream.
+func RebaseInteractive(base, editor string) error {
+	args := []string{"rebase", "--interactive"}
+	if base != "" {
+		args = append(args, base)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR="+editor)
+	if err := cmd.Run(); err != nil {
+		// git has already told the user what went wrong
+		return &Error{Args: args, Err: err}
+	}
+	return nil
+}
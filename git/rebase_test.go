@@ -0,0 +1,33 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTodo(t *testing.T) {
+	t.Parallel()
+
+	todo := ParseTodo(`pick 1111111 first
+f 2222222 fix first
+fixup -C 3333333 amend first
+x make test
+
+# Rebase 0000000..3333333 onto 0000000 (3 commands)
+`)
+
+	want := []TodoLine{
+		{Command: Pick, OID: "1111111", Text: "first"},
+		{Command: Fixup, OID: "2222222", Text: "fix first"},
+		{Command: Fixup, Text: "-C 3333333 amend first"},
+		{Command: Exec, Text: "make test"},
+	}
+	if !reflect.DeepEqual(todo, want) {
+		t.Fatalf("todo = %+v, want %+v", todo, want)
+	}
+
+	formatted := "pick 1111111 first\nfixup 2222222 fix first\nfixup -C 3333333 amend first\nexec make test\n"
+	if got := FormatTodo(todo); got != formatted {
+		t.Errorf("formatted = %q, want %q", got, formatted)
+	}
+}
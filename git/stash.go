@@ -0,0 +1,119 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Stash struct {
+	// Ref names the entry, e.g. "stash@{0}". It shifts as entries are added or
+	// dropped.
+	Ref     string
+	OID     string
+	Message string
+	Date    time.Time
+}
+
+// Stashes lists the stash entries, most recent first.
+func Stashes() ([]Stash, error) {
+	stdout, err := execGit("stash", "list", "-z", "--format=%gd%x1f%H%x1f%ct%x1f%gs")
+	if err != nil {
+		return nil, err
+	}
+	return parseStashes(stdout), nil
+}
+
+func parseStashes(b []byte) []Stash {
+	var stashes []Stash
+	for _, record := range strings.Split(string(b), "\x00") {
+		fields := strings.SplitN(strings.TrimPrefix(record, "\n"), "\x1f", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		stash := Stash{Ref: fields[0], OID: fields[1], Message: fields[3]}
+		if secs, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			stash.Date = time.Unix(secs, 0)
+		}
+		stashes = append(stashes, stash)
+	}
+	return stashes
+}
+
+// StashDiff returns the changes recorded in a stash entry, including untracked
+// files.
+func StashDiff(ref string) ([]FileDiff, error) {
+	stdout, err := execGit("stash", "show", "--patch", "--include-untracked",
+		"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", ref)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiff(stdout), nil
+}
+
+// StashPush stashes the changes to the given paths, untracked ones included,
+// and leaves everything else alone. An empty message lets git describe the
+// entry.
+func StashPush(message string, paths ...string) error {
+	args := []string{"stash", "push", "--include-untracked"}
+	if message != "" {
+		args = append(args, "--message="+message)
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	_, err := execGit(args...)
+	return err
+}
+
+func StashApply(ref string) error {
+	_, err := execGit("stash", "apply", ref)
+	return err
+}
+
+func StashPop(ref string) error {
+	_, err := execGit("stash", "pop", ref)
+	return err
+}
+
+func StashDrop(ref string) error {
+	_, err := execGit("stash", "drop", ref)
+	return err
+}
+
+// StashBranch checks out a new branch at the commit the stash entry was
+// created on, applies the entry and drops it.
+func StashBranch(name, ref string) error {
+	_, err := execGit("stash", "branch", name, ref)
+	return err
+}
+
+// StashRename changes the message of a stash entry. Git has no way to edit an
+// entry in place, so it is stored again, which moves it to the top of the
+// list, and only then dropped from its old place. A failure leaves the entry
+// where it was.
+func StashRename(ref, message string) error {
+	n, err := stashIndex(ref)
+	if err != nil {
+		return err
+	}
+	oid, err := execGit("rev-parse", "--verify", ref)
+	if err != nil {
+		return err
+	}
+	if _, err := execGit("stash", "store", "--message="+message, string(oid)); err != nil {
+		return err
+	}
+	_, err = execGit("stash", "drop", fmt.Sprintf("stash@{%d}", n+1))
+	return err
+}
+
+// stashIndex returns the position of an entry named like stash@{2}.
+func stashIndex(ref string) (int, error) {
+	if s, ok := strings.CutPrefix(ref, "stash@{"); ok && strings.HasSuffix(s, "}") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, "}")); err == nil && n >= 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%q does not name a stash entry", ref)
+}
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SubmoduleState describes the state of a submodule entry. It is the zero
+// value for regular files.
+type SubmoduleState struct {
+	IsSubmodule   bool
+	CommitChanged bool
+	Modified      bool
+	Untracked     bool
+}
+
+// FileStatus is a single entry of "git status --porcelain=v2".
+type FileStatus struct {
+	Path string
+	// OrigPath is the path in HEAD or the index for renamed and copied files.
+	OrigPath string
+	Staged   StatusCode
+	Tracked  StatusCode
+
+	Submodule SubmoduleState
+
+	ModeHead     string
+	ModeIndex    string
+	ModeWorktree string
+	HeadID       string
+	IndexID      string
+
+	// Score is the similarity percentage of a rename or copy.
+	Score int
+
+	// StageModes and StageIDs hold stages 1 to 3 of unmerged entries.
+	StageModes [3]string
+	StageIDs   [3]string
+}
+
+func (f FileStatus) Unmerged() bool {
+	return f.StageIDs[0] != "" || f.StageIDs[1] != "" || f.StageIDs[2] != ""
+}
+
+// BranchInfo holds the branch header of "git status --porcelain=v2 --branch".
+type BranchInfo struct {
+	// OID is the commit HEAD points to, empty on an unborn branch.
+	OID string
+	// Head is the name of the current branch, empty if HEAD is detached.
+	Head     string
+	Upstream string
+	// Ahead and Behind count the commits against the upstream. They are zero
+	// if the commits were not counted.
+	Ahead  int
+	Behind int
+}
+
+type StatusInfo struct {
+	Branch BranchInfo
+	Files  []FileStatus
+}
+
+// StatusOptions adjusts what Status reads.
+type StatusOptions struct {
+	// NoAheadBehind skips counting the commits against the upstream, which can
+	// be slow on large histories.
+	NoAheadBehind bool
+}
+
+func Status(opts StatusOptions) (StatusInfo, error) {
+	args := []string{"status", "--porcelain=v2", "-z", "--branch"}
+	if opts.NoAheadBehind {
+		args = append(args, "--no-ahead-behind")
+	}
+	stdout, err := execGitEnv(noLocks, args...)
+	if err != nil {
+		return StatusInfo{}, err
+	}
+	return ParseStatus(stdout), nil
+}
+
+// ParseStatus parses the NUL-terminated output of
+// "git status --porcelain=v2 -z --branch".
+func ParseStatus(b []byte) StatusInfo {
+	var info StatusInfo
+
+	records := strings.Split(string(b), "\x00")
+	for i := 0; i < len(records); i++ {
+		r := records[i]
+		if len(r) < 2 {
+			continue
+		}
+
+		switch r[0] {
+		case '#':
+			parseBranchHeader(r, &info.Branch)
+		case '1':
+			fields := strings.SplitN(r, " ", 9)
+			if len(fields) < 9 {
+				continue
+			}
+			f := parseChanged(fields)
+			f.Path = fields[8]
+			info.Files = append(info.Files, f)
+		case '2':
+			fields := strings.SplitN(r, " ", 10)
+			if len(fields) < 10 {
+				continue
+			}
+			f := parseChanged(fields)
+			f.Score, _ = strconv.Atoi(fields[8][1:])
+			f.Path = fields[9]
+			// the original path follows as a separate record
+			if i+1 < len(records) {
+				i++
+				f.OrigPath = records[i]
+			}
+			info.Files = append(info.Files, f)
+		case 'u':
+			fields := strings.SplitN(r, " ", 11)
+			if len(fields) < 11 {
+				continue
+			}
+			f := FileStatus{
+				Staged:       statusCode(fields[1][0]),
+				Tracked:      statusCode(fields[1][1]),
+				Submodule:    parseSubmodule(fields[2]),
+				StageModes:   [3]string{fields[3], fields[4], fields[5]},
+				ModeWorktree: fields[6],
+				StageIDs:     [3]string{fields[7], fields[8], fields[9]},
+				Path:         fields[10],
+			}
+			info.Files = append(info.Files, f)
+		case '?':
+			info.Files = append(info.Files, FileStatus{
+				Path:    r[2:],
+				Staged:  Untracked,
+				Tracked: Untracked,
+			})
+		}
+	}
+
+	return info
+}
+
+func parseBranchHeader(r string, b *BranchInfo) {
+	name, value, _ := strings.Cut(strings.TrimPrefix(r, "# "), " ")
+	switch name {
+	case "branch.oid":
+		if value != "(initial)" {
+			b.OID = value
+		}
+	case "branch.head":
+		if value != "(detached)" {
+			b.Head = value
+		}
+	case "branch.upstream":
+		b.Upstream = value
+	case "branch.ab":
+		// "+? -?" if the commits were not counted, which leaves them at zero
+		ahead, behind, _ := strings.Cut(value, " ")
+		b.Ahead, _ = strconv.Atoi(strings.TrimPrefix(ahead, "+"))
+		b.Behind, _ = strconv.Atoi(strings.TrimPrefix(behind, "-"))
+	}
+}
+
+// parseChanged parses the fields shared by ordinary and renamed entries.
+func parseChanged(fields []string) FileStatus {
+	return FileStatus{
+		Staged:       statusCode(fields[1][0]),
+		Tracked:      statusCode(fields[1][1]),
+		Submodule:    parseSubmodule(fields[2]),
+		ModeHead:     fields[3],
+		ModeIndex:    fields[4],
+		ModeWorktree: fields[5],
+		HeadID:       fields[6],
+		IndexID:      fields[7],
+	}
+}
+
+func parseSubmodule(s string) SubmoduleState {
+	if len(s) < 4 || s[0] != 'S' {
+		return SubmoduleState{}
+	}
+	return SubmoduleState{
+		IsSubmodule:   true,
+		CommitChanged: s[1] == 'C',
+		Modified:      s[2] == 'M',
+		Untracked:     s[3] == 'U',
+	}
+}
+
+// statusCode converts a porcelain v2 status letter, which uses '.' rather than
+// ' ' for unmodified.
+func statusCode(c byte) StatusCode {
+	if c == '.' {
+		return Unmodified
+	}
+	return StatusCode(c)
+}
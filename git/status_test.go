@@ -0,0 +1,112 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	records := []string{
+		"# branch.oid 57c87cb5ac16a0ef300322671dbf46dd53cf4684",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"1 .M N... 100644 100644 100644 1111111111111111111111111111111111111111 1111111111111111111111111111111111111111 with space -> arrow.txt",
+		"2 R. N... 100644 100644 100644 2222222222222222222222222222222222222222 2222222222222222222222222222222222222222 R87 new name.txt",
+		"old name.txt",
+		"1 A. SC.. 000000 160000 160000 0000000000000000000000000000000000000000 3333333333333333333333333333333333333333 vendor/lib",
+		"u UU N... 100644 100644 100644 100644 4444444444444444444444444444444444444444 5555555555555555555555555555555555555555 6666666666666666666666666666666666666666 conflict.go",
+		"? line\nbreak.txt",
+		"",
+	}
+	info := ParseStatus([]byte(strings.Join(records, "\x00")))
+
+	wantBranch := BranchInfo{
+		OID:      "57c87cb5ac16a0ef300322671dbf46dd53cf4684",
+		Head:     "main",
+		Upstream: "origin/main",
+		Ahead:    2,
+		Behind:   1,
+	}
+	if info.Branch != wantBranch {
+		t.Errorf("branch = %+v, want %+v", info.Branch, wantBranch)
+	}
+
+	want := []FileStatus{
+		{
+			Path:         "with space -> arrow.txt",
+			Staged:       Unmodified,
+			Tracked:      Modified,
+			ModeHead:     "100644",
+			ModeIndex:    "100644",
+			ModeWorktree: "100644",
+			HeadID:       "1111111111111111111111111111111111111111",
+			IndexID:      "1111111111111111111111111111111111111111",
+		},
+		{
+			Path:         "new name.txt",
+			OrigPath:     "old name.txt",
+			Staged:       Renamed,
+			Tracked:      Unmodified,
+			ModeHead:     "100644",
+			ModeIndex:    "100644",
+			ModeWorktree: "100644",
+			HeadID:       "2222222222222222222222222222222222222222",
+			IndexID:      "2222222222222222222222222222222222222222",
+			Score:        87,
+		},
+		{
+			Path:         "vendor/lib",
+			Staged:       Added,
+			Tracked:      Unmodified,
+			Submodule:    SubmoduleState{IsSubmodule: true, CommitChanged: true},
+			ModeHead:     "000000",
+			ModeIndex:    "160000",
+			ModeWorktree: "160000",
+			HeadID:       "0000000000000000000000000000000000000000",
+			IndexID:      "3333333333333333333333333333333333333333",
+		},
+		{
+			Path:         "conflict.go",
+			Staged:       UpdatedButUnmerged,
+			Tracked:      UpdatedButUnmerged,
+			StageModes:   [3]string{"100644", "100644", "100644"},
+			ModeWorktree: "100644",
+			StageIDs: [3]string{
+				"4444444444444444444444444444444444444444",
+				"5555555555555555555555555555555555555555",
+				"6666666666666666666666666666666666666666",
+			},
+		},
+		{
+			Path:    "line\nbreak.txt",
+			Staged:  Untracked,
+			Tracked: Untracked,
+		},
+	}
+	if !reflect.DeepEqual(info.Files, want) {
+		t.Errorf("files =\n%+v\nwant\n%+v", info.Files, want)
+	}
+}
+
+func TestParseStatusNoAheadBehind(t *testing.T) {
+	t.Parallel()
+
+	info := ParseStatus([]byte("# branch.head main\x00# branch.upstream origin/main\x00# branch.ab +? -?\x00"))
+	want := BranchInfo{Head: "main", Upstream: "origin/main"}
+	if info.Branch != want {
+		t.Errorf("branch = %+v, want %+v", info.Branch, want)
+	}
+}
+
+func TestParseStatusDetached(t *testing.T) {
+	t.Parallel()
+
+	info := ParseStatus([]byte("# branch.oid (initial)\x00# branch.head (detached)\x00"))
+	if info.Branch != (BranchInfo{}) {
+		t.Errorf("branch = %+v, want zero value", info.Branch)
+	}
+}
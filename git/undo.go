@@ -0,0 +1,289 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UndoRef keeps the snapshots got takes of changes before it discards them.
+// Each snapshot is an entry in the reflog of the ref, so they expire along with
+// the rest of the reflogs.
+const UndoRef = "refs/got/undo"
+
+// Snapshot is the content some paths had in the worktree, or in the index,
+// before it was discarded. It is a commit on top of HEAD, with the paths listed in its body.
+type Snapshot struct {
+	// Ref names the entry, e.g. "refs/got/undo@{0}". It shifts as snapshots are
+	// taken or dropped.
+	Ref     string
+	OID     string
+	Message string
+	// Paths are relative to the top level of the worktree.
+	Paths []string
+	Date  time.Time
+	// Staged is set for the content of the index, which is restored there.
+	Staged bool
+}
+
+// stagedTrailer follows the paths in the body of a snapshot of the index.
+const stagedTrailer = "Got-Snapshot: staged"
+
+// snapshotIdent signs the snapshots, which are no one's work but got's and
+// should not depend on the user being set up to commit.
+var snapshotIdent = []string{
+	"GIT_AUTHOR_NAME=got", "GIT_AUTHOR_EMAIL=got@localhost",
+	"GIT_COMMITTER_NAME=got", "GIT_COMMITTER_EMAIL=got@localhost",
+}
+
+// SaveSnapshot records the worktree content of paths on UndoRef. The index is
+// left alone, as the snapshot is built in a temporary one.
+func SaveSnapshot(message string, paths ...string) error {
+	return saveSnapshot(message, false, paths)
+}
+
+// SaveStagedSnapshot records the staged content of paths on UndoRef, for
+// changes that are about to be dropped from the index but are not in the
+// worktree. Restoring it brings the content back into the index.
+func SaveStagedSnapshot(message string, paths ...string) error {
+	return saveSnapshot(message, true, paths)
+}
+
+func saveSnapshot(message string, staged bool, paths []string) error {
+	top, err := execGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	rel := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		r, err := filepath.Rel(string(top), abs)
+		if err != nil {
+			return err
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+
+	dir, err := os.MkdirTemp("", "got-snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(dir, "index")}
+
+	head, err := execGit("rev-parse", "--verify", "--quiet", "HEAD")
+	// an unborn branch starts from an empty tree
+	hasHead := err == nil
+	if hasHead {
+		if _, err := execGitEnv(env, "read-tree", string(head)); err != nil {
+			return err
+		}
+	}
+	if staged {
+		err = copyEntries(env, paths, rel)
+	} else {
+		args := append([]string{"update-index", "--add", "--remove", "--"}, paths...)
+		_, err = execGitEnv(env, args...)
+	}
+	if err != nil {
+		return err
+	}
+	tree, err := execGitEnv(env, "write-tree")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"commit-tree", string(tree)}
+	if hasHead {
+		args = append(args, "-p", string(head))
+	}
+	body := message + "\n\n" + strings.Join(rel, "\n") + "\n"
+	if staged {
+		body += "\n" + stagedTrailer + "\n"
+	}
+	commit, err := run([]byte(body), snapshotIdent, args...)
+	if err != nil {
+		return err
+	}
+	_, err = execGit("update-ref", "--create-reflog", "-m", message, UndoRef, string(commit))
+	return err
+}
+
+// copyEntries copies the index entries of paths, given as is and relative to
+// the top level, into the index of env. Paths the index does not have are
+// removed from it.
+func copyEntries(env []string, paths, rel []string) error {
+	pathspecs := make([]string, 0, len(rel))
+	for _, p := range rel {
+		pathspecs = append(pathspecs, ":(top,literal)"+p)
+	}
+	args := append([]string{"ls-files", "--stage", "-z", "--full-name", "--"}, pathspecs...)
+	stdout, err := execGit(args...)
+	if err != nil {
+		return err
+	}
+
+	var info strings.Builder
+	listed := make(map[string]bool)
+	for _, entry := range strings.Split(string(stdout), "\x00") {
+		meta, p, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		listed[p] = true
+		// conflicts keep the version of HEAD
+		if fields := strings.Fields(meta); len(fields) == 3 && fields[2] == "0" {
+			info.WriteString(entry + "\x00")
+		}
+	}
+	if info.Len() > 0 {
+		if _, err := run([]byte(info.String()), env, "update-index", "-z", "--index-info"); err != nil {
+			return err
+		}
+	}
+
+	remove := []string{"update-index", "--force-remove", "--"}
+	for i, p := range rel {
+		if !listed[p] {
+			remove = append(remove, paths[i])
+		}
+	}
+	if len(remove) == 3 {
+		return nil
+	}
+	_, err = execGitEnv(env, remove...)
+	return err
+}
+
+// Snapshots lists the snapshots on UndoRef, most recent first.
+func Snapshots() ([]Snapshot, error) {
+	if _, err := execGit("rev-parse", "--verify", "--quiet", UndoRef); err != nil {
+		// nothing was saved yet
+		return nil, nil
+	}
+	stdout, err := execGit("log", "--walk-reflogs", "-z", "--format=%gd%x1f%H%x1f%ct%x1f%gs%x1f%b", UndoRef)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshots(stdout), nil
+}
+
+func parseSnapshots(b []byte) []Snapshot {
+	var snapshots []Snapshot
+	for _, record := range strings.Split(string(b), "\x00") {
+		fields := strings.SplitN(strings.TrimPrefix(record, "\n"), "\x1f", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		s := Snapshot{Ref: fields[0], OID: fields[1], Message: fields[3]}
+		if secs, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			s.Date = time.Unix(secs, 0)
+		}
+		paths, trailers, _ := strings.Cut(fields[4], "\n\n")
+		for _, p := range strings.Split(paths, "\n") {
+			if p != "" {
+				s.Paths = append(s.Paths, p)
+			}
+		}
+		s.Staged = strings.Contains("\n"+trailers+"\n", "\n"+stagedTrailer+"\n")
+		snapshots = append(snapshots, s)
+	}
+	return snapshots
+}
+
+// RestoreSnapshot writes the content saved in a snapshot back to the worktree,
+// or to the index for a snapshot of the index, and drops the snapshot. Paths
+// that did not exist when it was taken are removed. Unless force is set, it
+// refuses to overwrite paths that changed again since they were discarded.
+func RestoreSnapshot(s Snapshot, force bool) error {
+	top, err := execGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	pathspecs := make([]string, 0, len(s.Paths))
+	for _, p := range s.Paths {
+		pathspecs = append(pathspecs, ":(top,literal)"+p)
+	}
+
+	if !force {
+		// unstaging left the index as HEAD has it, and discarding the worktree
+		// as the index has it
+		args := []string{"diff", "--name-only"}
+		if s.Staged {
+			args = append(args, "--cached")
+		}
+		args = append(append(args, "--"), pathspecs...)
+		changed, err := execGit(args...)
+		if err != nil {
+			return err
+		}
+		if len(changed) > 0 {
+			return fmt.Errorf("%s changed since the snapshot was taken; -f overwrites the changes",
+				strings.Join(strings.Split(string(changed), "\n"), ", "))
+		}
+	}
+
+	args := append([]string{"ls-tree", "-r", "-z", "--name-only", "--full-tree", s.OID, "--"}, s.Paths...)
+	stdout, err := execGit(args...)
+	if err != nil {
+		return err
+	}
+	saved := make(map[string]bool)
+	for _, p := range strings.Split(string(stdout), "\x00") {
+		saved[p] = true
+	}
+
+	var restore, remove []string
+	for i, p := range s.Paths {
+		if saved[p] {
+			restore = append(restore, pathspecs[i])
+			continue
+		}
+		if s.Staged {
+			remove = append(remove, filepath.Join(string(top), filepath.FromSlash(p)))
+			continue
+		}
+		err := os.Remove(filepath.Join(string(top), filepath.FromSlash(p)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		args := append([]string{"update-index", "--force-remove", "--"}, remove...)
+		if _, err := execGit(args...); err != nil {
+			return err
+		}
+	}
+	if len(restore) > 0 {
+		target := "--worktree"
+		if s.Staged {
+			target = "--staged"
+		}
+		args := append([]string{"restore", "--source=" + s.OID, target, "--"}, restore...)
+		if _, err := execGit(args...); err != nil {
+			return err
+		}
+	}
+	return DropSnapshot(s)
+}
+
+// DropSnapshot removes a snapshot from the reflog of UndoRef, and the ref along
+// with the last one.
+func DropSnapshot(s Snapshot) error {
+	if _, err := execGit("reflog", "delete", "--updateref", "--rewrite", s.Ref); err != nil {
+		return err
+	}
+	left, err := Snapshots()
+	if err != nil || len(left) > 0 {
+		return err
+	}
+	_, err = execGit("update-ref", "-d", UndoRef)
+	return err
+}
@@ -0,0 +1,36 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSnapshots(t *testing.T) {
+	t.Parallel()
+
+	out := "refs/got/undo@{0}\x1f1111111111111111111111111111111111111111\x1f1700000000\x1fdiscard 2 files\x1fa.go\nsrc/b c.go\n" +
+		"\x00\nrefs/got/undo@{1}\x1f2222222222222222222222222222222222222222\x1f1600000000\x1funstage x.go\x1fx.go\n\nGot-Snapshot: staged\n"
+	got := parseSnapshots([]byte(out))
+
+	want := []Snapshot{
+		{
+			Ref:     "refs/got/undo@{0}",
+			OID:     "1111111111111111111111111111111111111111",
+			Message: "discard 2 files",
+			Paths:   []string{"a.go", "src/b c.go"},
+			Date:    time.Unix(1700000000, 0),
+		},
+		{
+			Ref:     "refs/got/undo@{1}",
+			OID:     "2222222222222222222222222222222222222222",
+			Message: "unstage x.go",
+			Paths:   []string{"x.go"},
+			Date:    time.Unix(1600000000, 0),
+			Staged:  true,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
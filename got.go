@@ -10,12 +10,19 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/cv4x/got/color"
 	"github.com/cv4x/got/commands"
+	"github.com/cv4x/got/config"
 	"github.com/cv4x/got/git"
 )
 
 const (
 	status = "status"
+	stash  = "stash"
+	branch = "branch"
+	logs   = "log"
+	rebase = "rebase"
+	undo   = "undo"
 )
 
 func main() {
@@ -26,36 +33,69 @@ func main() {
 		os.Exit(0)
 	}()
 
-	state := git.CurrentRef()
-	if state.Ref == "" {
-		panic("panic: no git repository")
+	args, overrides := flags()
+
+	state, err := git.CurrentRef()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg, err := config.Load(overrides...)
+	if err == nil {
+		err = commands.Configure(cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	// Output the colorized status before exiting
+	// Output the status before exiting, colored if the views are
 	printstatus := func() {
-		stdout, err := exec.Command("git", "-c", "color.ui=always", "status").Output()
+		mode := "never"
+		if color.Enabled() {
+			mode = "always"
+		}
+		stdout, err := exec.Command("git", "-c", "color.status="+mode, "status").Output()
 		if err == nil {
 			fmt.Println(string(stdout))
 		}
 	}
 
-	args := flags()
 	if len(args) == 0 {
-		commands.Status(state, args)
-		printstatus()
-	} else {
-		switch strings.ToLower(args[0]) {
-		case status:
-			commands.Status(state, args[1:])
-			printstatus()
-		default:
-			fmt.Printf("%s is not a known command\n\n", args[0])
-			flag.Usage()
+		args = []string{cfg.Command}
+	}
+	switch strings.ToLower(args[0]) {
+	case status:
+		err = commands.Status(state, args[1:])
+	case stash:
+		err = commands.Stash(state, args[1:])
+	case branch:
+		err = commands.Branch(state, args[1:])
+	case logs:
+		err = commands.Log(state, args[1:])
+	case rebase:
+		// git reports on the rebase itself, and the status would get in
+		// its way while got is its sequence editor
+		if err = commands.Rebase(state, args[1:]); err == nil {
+			return
 		}
+	case undo:
+		err = commands.Undo(state, args[1:])
+	default:
+		fmt.Printf("%s is not a known command\n\n", args[0])
+		flag.Usage()
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	printstatus()
 }
 
-func flags() []string {
+// flags parses the common flags, returning the rest of the arguments and the
+// settings given with -c.
+func flags() (args, overrides []string) {
 	ex := filepath.Base(os.Args[0])
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage of %[1]s:
@@ -63,15 +103,35 @@ func flags() []string {
 
 Commands:
 	status      View worktree status and add/restore files.
+	stash       Browse stash entries and apply, pop, drop or branch from them.
+	branch      Switch between, create, rename and delete branches.
+	log         Browse the commit graph and check out, cherry-pick or revert commits.
+	rebase      Reorder, reword, squash, fix up or drop commits with rebase -i.
+	undo        Bring back changes that status restored or resolved away.
 
 Common Flags:
-	None yet.
 `, ex)
 		flag.PrintDefaults()
-	}
+		fmt.Fprintf(os.Stderr, `
+Settings:
+	Read from %[1]s, the [got] section of
+	.git/config, the environment and -c, each overriding the one before.
 
-	// TODO add flags
+%[2]s`, userConfig(), config.Usage())
+	}
+	flag.Func("c", "set a setting for this run, as in -c width=100", func(s string) error {
+		overrides = append(overrides, s)
+		return nil
+	})
 
 	flag.Parse()
-	return flag.Args()
+	return flag.Args(), overrides
+}
+
+// userConfig names the user's config file for the usage, or its usual place.
+func userConfig() string {
+	if path, err := config.File(); err == nil {
+		return path
+	}
+	return "$XDG_CONFIG_HOME/got/config"
 }
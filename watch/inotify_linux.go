@@ -0,0 +1,141 @@
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	worktreeEvents = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB |
+		unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+	// git replaces the index by renaming a lock file over it
+	gitDirEvents = unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY
+)
+
+// notifyChanges watches every directory of the worktree with inotify. It fails
+// if any of them cannot be watched, e.g. because the limit on the number of
+// watches is exceeded.
+func (w *Watcher) notifyChanges() error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[int]string)
+	if err := w.addTree(fd, w.root, dirs); err != nil {
+		unix.Close(fd)
+		return err
+	}
+	wd, err := unix.InotifyAddWatch(fd, w.gitDir, gitDirEvents)
+	if err != nil {
+		unix.Close(fd)
+		return err
+	}
+	dirs[wd] = w.gitDir
+
+	w.wg.Add(1)
+	go w.read(fd, dirs)
+	return nil
+}
+
+// addTree watches dir and all directories below it that are not skipped.
+func (w *Watcher) addTree(fd int, dir string, dirs map[int]string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the directory may have been removed while walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.skip[path] {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(fd, path, worktreeEvents|unix.IN_ONLYDIR)
+		if err != nil {
+			return err
+		}
+		dirs[wd] = path
+		return nil
+	})
+}
+
+func (w *Watcher) read(fd int, dirs map[int]string) {
+	defer w.wg.Done()
+	defer unix.Close(fd)
+
+	buf := make([]byte, 64*1024)
+	var last time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(debounce/time.Millisecond)/3)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return
+		}
+		if n <= 0 {
+			if !last.IsZero() && time.Since(last) >= debounce {
+				w.changed()
+				last = time.Time{}
+			}
+			continue
+		}
+
+		n, err = unix.Read(fd, buf)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+
+			dir, ok := dirs[int(event.Wd)]
+			switch {
+			case event.Mask&unix.IN_Q_OVERFLOW != 0:
+				last = time.Now()
+			case !ok:
+				continue
+			case dir == w.gitDir:
+				if relevant(name) {
+					last = time.Now()
+				}
+			case event.Mask&unix.IN_IGNORED != 0:
+				delete(dirs, int(event.Wd))
+			default:
+				path := filepath.Join(dir, name)
+				if w.skip[path] {
+					continue
+				}
+				if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					// a failure only means changes below the new directory go unnoticed
+					_ = w.addTree(fd, path, dirs)
+				}
+				last = time.Now()
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+func (w *Watcher) notifyChanges() error {
+	return errors.ErrUnsupported
+}
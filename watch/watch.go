@@ -0,0 +1,139 @@
+// Package watch reports changes to a worktree and its git directory, so that
+// views can be kept up to date while files are edited elsewhere.
+package watch
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Bursts of changes, like a checkout or a build, are reported once they have
+	// been quiet for this long.
+	debounce = 150 * time.Millisecond
+	// PollInterval is how often the worktree, the index and HEAD are checked
+	// for changes when the platform offers no way of being notified about them.
+	PollInterval = 2 * time.Second
+)
+
+// Watcher reports changes through Changes. Changes are coalesced, so a receive
+// means that something changed since the previous receive.
+type Watcher struct {
+	Changes <-chan struct{}
+
+	changes chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	root   string
+	gitDir string
+	skip   map[string]bool
+}
+
+// New starts watching the worktree at root and the index in gitDir. Paths in
+// ignored are relative to root and are not watched. If the platform cannot
+// notify about changes, the watcher falls back to polling.
+func New(root, gitDir string, ignored []string) *Watcher {
+	changes := make(chan struct{}, 1)
+	w := &Watcher{
+		Changes: changes,
+		changes: changes,
+		done:    make(chan struct{}),
+		root:    filepath.Clean(root),
+		gitDir:  filepath.Clean(gitDir),
+		skip:    make(map[string]bool, len(ignored)+1),
+	}
+
+	w.skip[filepath.Join(w.root, ".git")] = true
+	for _, v := range ignored {
+		w.skip[filepath.Join(w.root, strings.TrimSuffix(v, "/"))] = true
+	}
+
+	if err := w.notifyChanges(); err != nil {
+		w.poll()
+	}
+	return w
+}
+
+// Close stops the watcher and closes Changes.
+func (w *Watcher) Close() {
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		close(w.changes)
+	})
+}
+
+func (w *Watcher) changed() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// relevant reports whether a change to the named entry of the git directory
+// affects the status of the worktree.
+func relevant(name string) bool {
+	return name == "index" || name == "HEAD"
+}
+
+// poll reports a change whenever a file in the worktree, the index or HEAD
+// was written since the last check.
+func (w *Watcher) poll() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		last := w.stamp()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+				if s := w.stamp(); s != last {
+					last = s
+					w.changed()
+				}
+			}
+		}
+	}()
+}
+
+// stamp identifies the versions of the index, HEAD and the files of the
+// worktree that are not skipped, by hashing their paths, modification times and
+// sizes. Directories count too, as creating or removing an entry changes them.
+func (w *Watcher) stamp() uint64 {
+	h := fnv.New64a()
+	add := func(path string, info fs.FileInfo) {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.ModTime().UnixNano(), info.Size())
+	}
+
+	for _, name := range []string{"index", "HEAD"} {
+		if info, err := os.Stat(filepath.Join(w.gitDir, name)); err == nil {
+			add(name, info)
+		}
+	}
+	filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the entry may have been removed while walking
+			return nil
+		}
+		if d.IsDir() && w.skip[path] {
+			return filepath.SkipDir
+		}
+		if info, err := d.Info(); err == nil {
+			add(path, info)
+		}
+		return nil
+	})
+	return h.Sum64()
+}